@@ -3,12 +3,13 @@ module github.com/snark/darling
 go 1.13
 
 require (
-	github.com/PuerkitoBio/goquery v1.5.0 // indirect
+	github.com/PuerkitoBio/goquery v1.5.0
 	github.com/gorilla/feeds v1.1.1
+	github.com/klauspost/compress v1.11.13
 	github.com/mmcdole/gofeed v1.0.0-beta2
 	github.com/mmcdole/goxpp v0.0.0-20181012175147-0068e33feabf // indirect
 	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.4.0 // indirect
-	golang.org/x/net v0.0.0-20190916140828-c8589233b77d // indirect
-	golang.org/x/text v0.3.2 // indirect
+	golang.org/x/net v0.0.0-20190916140828-c8589233b77d
+	golang.org/x/text v0.3.2
 )
@@ -0,0 +1,215 @@
+package darling
+
+import (
+	"encoding/json"
+	"github.com/snark/darling/pkg/feed"
+	"github.com/snark/darling/pkg/filter"
+	"github.com/snark/darling/pkg/output"
+	"io/ioutil"
+	"time"
+)
+
+// Config is a JSON file which can stand in for the command line. Its
+// lists of feeds, terms and routes are added to any given as arguments;
+// anything else given as an argument takes precedence.
+type Config struct {
+	Feeds      []string        `json:"feeds"`
+	Blacklist  []string        `json:"blacklist"`
+	Whitelist  []string        `json:"whitelist"`
+	Since      string          `json:"since"`
+	Limit      int             `json:"limit"`
+	Output     string          `json:"output"`
+	OutputFile string          `json:"outputFile"`
+	Routes     []RouteConfig   `json:"routes"`
+	Metadata   output.Metadata `json:"metadata"`
+	// PrefixSource prefixes item titles with their feed's
+	PrefixSource bool   `json:"prefixSource"`
+	Undated      string `json:"undated"`
+	// BlacklistFiles and WhitelistFiles are paths or URLs of term lists
+	BlacklistFiles []string `json:"blacklistFiles"`
+	WhitelistFiles []string `json:"whitelistFiles"`
+	// Score holds weighted terms, like "crypto:-2"
+	Score       []string `json:"score"`
+	ScoreFields []string `json:"scoreFields"`
+	MinScore    float64  `json:"minScore"`
+	Classifier  string   `json:"classifier"`
+	MinProb     float64  `json:"minProb"`
+	// FullText lists feeds whose items should be replaced with the
+	// articles they link to; they needn't also be listed in Feeds.
+	FullText []string `json:"fulltext"`
+	Sanitize string   `json:"sanitize"`
+	// SanitizePolicy replaces the default allowlist of HTML elements
+	// and URL schemes
+	SanitizePolicy *output.Policy `json:"sanitizePolicy"`
+	// CleanLinks removes the usual tracking parameters from links, and
+	// StripParams any others
+	CleanLinks       bool     `json:"cleanLinks"`
+	StripParams      []string `json:"stripParams"`
+	ResolveRedirects bool     `json:"resolveRedirects"`
+	// Rewrites fix up items from all feeds, or particular ones
+	Rewrites []RewriteConfig `json:"rewrites"`
+	Tags     []TagConfig     `json:"tags"`
+	// Summary is a length in characters, or sentences as in "2s"
+	Summary     string `json:"summary"`
+	DropContent bool   `json:"dropContent"`
+	MinWords    int    `json:"minWords"`
+	MaxWords    int    `json:"maxWords"`
+	// MinReadingTime and MaxReadingTime are durations, like "10m"
+	MinReadingTime string `json:"minReadingTime"`
+	MaxReadingTime string `json:"maxReadingTime"`
+	ReadingTime    bool   `json:"readingTime"`
+}
+
+// RouteConfig is a route, as written in a config file. A route without
+// terms takes everything the other routes don't.
+type RouteConfig struct {
+	Output   string          `json:"output"`
+	Terms    []string        `json:"terms"`
+	Metadata output.Metadata `json:"metadata"`
+}
+
+// RewriteConfig is a rewrite rule, as written in a config file. Rules
+// apply to every feed unless given a source, and run before filtering
+// unless after is set.
+type RewriteConfig struct {
+	Field       string `json:"field"`
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+	Source      string `json:"source"`
+	After       bool   `json:"after"`
+}
+
+// TagConfig is a tagging rule, as written in a config file. Field, if
+// given, is the only one searched for the terms.
+type TagConfig struct {
+	Category string   `json:"category"`
+	Field    string   `json:"field"`
+	Terms    []string `json:"terms"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := json.Unmarshal(buf, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Apply folds the config into opts. given reports whether an option was
+// set on the command line, and so should be left alone.
+func (config *Config) Apply(opts *Options, given func(name string) bool) error {
+	opts.Blacklist = append(config.Blacklist, opts.Blacklist...)
+	opts.Whitelist = append(config.Whitelist, opts.Whitelist...)
+	opts.BlacklistFiles = append(config.BlacklistFiles, opts.BlacklistFiles...)
+	opts.WhitelistFiles = append(config.WhitelistFiles, opts.WhitelistFiles...)
+	opts.Scores = append(config.Score, opts.Scores...)
+	opts.ScoreFields = append(config.ScoreFields, opts.ScoreFields...)
+	opts.FullText = append(config.FullText, opts.FullText...)
+	opts.StripParams = append(config.StripParams, opts.StripParams...)
+	if !given("since") && config.Since != "" {
+		opts.Since = config.Since
+	}
+	if !given("limit") && config.Limit != 0 {
+		opts.Limit = config.Limit
+	}
+	if !given("output") && config.Output != "" {
+		opts.OutputType = config.Output
+	}
+	if !given("output-file") && config.OutputFile != "" {
+		opts.OutputFile = config.OutputFile
+	}
+	if !given("prefix-source") && config.PrefixSource {
+		opts.PrefixSource = true
+	}
+	if !given("undated") && config.Undated != "" {
+		opts.Undated = config.Undated
+	}
+	if !given("min-score") && config.MinScore != 0 {
+		opts.MinScore = config.MinScore
+	}
+	if !given("classifier") && config.Classifier != "" {
+		opts.Classifier = config.Classifier
+	}
+	if !given("min-prob") && config.MinProb != 0 {
+		opts.MinProb = config.MinProb
+	}
+	if !given("sanitize") && config.Sanitize != "" {
+		opts.Sanitize = config.Sanitize
+	}
+	if config.SanitizePolicy != nil {
+		opts.SanitizePolicy = config.SanitizePolicy
+	}
+	if !given("clean-links") && config.CleanLinks {
+		opts.CleanLinks = true
+	}
+	if !given("resolve-redirects") && config.ResolveRedirects {
+		opts.ResolveRedirects = true
+	}
+	if !given("summary") && config.Summary != "" {
+		summary, err := output.ParseSummary(config.Summary)
+		if err != nil {
+			return err
+		}
+		opts.Summary = summary
+	}
+	if !given("drop-content") && config.DropContent {
+		opts.DropContent = true
+	}
+	if !given("min-words") && config.MinWords != 0 {
+		opts.MinWords = config.MinWords
+	}
+	if !given("max-words") && config.MaxWords != 0 {
+		opts.MaxWords = config.MaxWords
+	}
+	if !given("min-reading-time") && config.MinReadingTime != "" {
+		d, err := time.ParseDuration(config.MinReadingTime)
+		if err != nil {
+			return err
+		}
+		opts.MinReadingTime = d
+	}
+	if !given("max-reading-time") && config.MaxReadingTime != "" {
+		d, err := time.ParseDuration(config.MaxReadingTime)
+		if err != nil {
+			return err
+		}
+		opts.MaxReadingTime = d
+	}
+	if !given("reading-time") && config.ReadingTime {
+		opts.ReadingTime = true
+	}
+	routes := []Route{}
+	for _, rc := range config.Routes {
+		route, err := NewRoute(rc.Output, rc.Terms)
+		if err != nil {
+			return err
+		}
+		route.Metadata = rc.Metadata
+		routes = append(routes, route)
+	}
+	opts.Routes = append(routes, opts.Routes...)
+	rewrites := []Rewrite{}
+	for _, rc := range config.Rewrites {
+		rule, err := feed.NewRewrite(rc.Field, rc.Pattern, rc.Replacement)
+		if err != nil {
+			return err
+		}
+		rewrites = append(rewrites, Rewrite{Rewrite: rule, Source: rc.Source, After: rc.After})
+	}
+	opts.Rewrites = append(rewrites, opts.Rewrites...)
+	tags := []*filter.Tag{}
+	for _, tc := range config.Tags {
+		tag, err := NewTag(tc.Category, tc.Field, tc.Terms)
+		if err != nil {
+			return err
+		}
+		tags = append(tags, tag)
+	}
+	opts.Tags = append(tags, opts.Tags...)
+	opts.Metadata = config.Metadata.Merge(opts.Metadata)
+	return nil
+}
@@ -1,10 +1,10 @@
 package darling
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"github.com/gorilla/feeds"
+	"github.com/mmcdole/gofeed"
 	"github.com/snark/darling/pkg/feed"
 	"github.com/snark/darling/pkg/filter"
 	"github.com/snark/darling/pkg/output"
@@ -12,127 +12,698 @@ import (
 	"log"
 	"net/url"
 	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
-func FilterFeeds(blacklistWords []string, whitelistWords []string, since *string, limit *int, outputType *string, feedTokens []string) {
-	var wg sync.WaitGroup
+// Options collects everything FilterFeeds needs to know about a single
+// invocation.
+type Options struct {
+	Blacklist  []string
+	Whitelist  []string
+	Since      string
+	Limit      int
+	OutputType string
+	// InputType is how STDIN and paths are read: as feeds, or as 'ndjson'
+	// items written by a previous run.
+	InputType string
+	// ExitEmpty is the exit status returned when the resulting feed has
+	// no items; zero leaves the status alone.
+	ExitEmpty int
+	// QuietIfUnchanged suppresses output when the result matches the
+	// one produced by the previous run with the same options.
+	QuietIfUnchanged bool
+	// OutputFile, if set, is atomically replaced with the result instead
+	// of printing it.
+	OutputFile string
+	// Routes, if any, each get an output file of their own.
+	Routes []Route
+	// Metadata describes the output feed itself.
+	Metadata output.Metadata
+	// PrefixSource prefixes each item's title with its feed's.
+	PrefixSource bool
+	// Undated is the policy for items without dates of their own; see
+	// feed.NewUndated.
+	Undated string
+	// BlacklistFiles and WhitelistFiles name files or URLs holding more
+	// terms, one per line.
+	BlacklistFiles []string
+	WhitelistFiles []string
+	// Scores are weighted terms (TERM:N) for a scoring filter, which
+	// passes items scoring at least MinScore. ScoreFields multiply the
+	// weights of terms found in each field (FIELD=N).
+	Scores      []string
+	ScoreFields []string
+	MinScore    float64
+	// Explain describes how each item was scored on STDERR.
+	Explain bool
+	// scoring, if set, is shared by every run of the filters, so that
+	// each item is only scored and explained once.
+	scoring *filter.WeightedTerms
+	// Classifier is the path of a model made by Train; only items it
+	// thinks are liked with at least MinProb pass.
+	Classifier string
+	MinProb    float64
+	model      *filter.Model
+	// feedModel, if set, gives the model trained by feedback on a feed's
+	// items, which is applied to that feed alone.
+	feedModel func(f *gofeed.Feed) *filter.Model
+	// FullText names feed locations whose items' content should be
+	// replaced with the articles they link to.
+	FullText []string
+	// Sanitize cleans the HTML in items before output: 'html' removes
+	// whatever SanitizePolicy (or output.DefaultPolicy) doesn't allow,
+	// and 'text' removes all of it.
+	Sanitize       string
+	SanitizePolicy *output.Policy
+	// CleanLinks removes feed.DefaultTrackingParams and StripParams from
+	// item links, and replaces AMP links with the pages they stand in
+	// for. ResolveRedirects also follows links to where they redirect.
+	CleanLinks       bool
+	StripParams      []string
+	ResolveRedirects bool
+	redirects        *feed.RedirectCache
+	// Rewrites fix up items with regular expressions.
+	Rewrites []Rewrite
+	// Tags give the items they match categories.
+	Tags []*filter.Tag
+	// Summary, if it has a length, replaces each item's description with
+	// a plain text summary. DropContent removes the content.
+	Summary     output.Summary
+	DropContent bool
+	// MinWords, MaxWords, MinReadingTime and MaxReadingTime limit how
+	// long the text of items may be; zero leaves a limit unset. Using
+	// any of them, or ReadingTime, adds each item's reading time to the
+	// output.
+	MinWords       int
+	MaxWords       int
+	MinReadingTime time.Duration
+	MaxReadingTime time.Duration
+	ReadingTime    bool
+}
 
-	blacklist := filter.NewRegexp(blacklistWords)
-	whitelist := filter.NewRegexp(whitelistWords)
-	wordMatch := filter.Or{&filter.Not{blacklist}, whitelist}
-	var sinceMatch filter.ItemFilter
-	var err error
-	if *since != "" {
-		sinceMatch, err = filter.NewSince(since, time.Now())
+// FilterFeeds fetches, filters and prints the feeds named by feedTokens,
+// returning the status the process should exit with.
+func FilterFeeds(opts Options, feedTokens []string) int {
+	files := newTermFiles(opts)
+	if _, err := files.reload(); err != nil {
+		log.Fatal(err)
+	}
+	opts = files.apply(opts)
+	if err := loadModel(&opts); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadScoring(&opts); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadRedirects(&opts); err != nil {
+		log.Fatal(err)
+	}
+	// Fail on a bad filter or policy before fetching anything
+	if _, err := newFilters(opts, time.Now()); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := feed.NewUndated(opts.Undated, &feed.SeenStore{}); err != nil {
+		log.Fatal(err)
+	}
+	if err := sanitize(opts, nil); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkMetadata(opts); err != nil {
+		log.Fatal(err)
+	}
+	parsed := fetchAll(opts, feedTokens)
+	if len(opts.Routes) > 0 {
+		routed, err := writeRoutes(opts, parsed)
 		if err != nil {
 			log.Fatal(err)
 		}
+		// Routes replace printed output, but -o still gets everything
+		if opts.OutputFile == "" {
+			if routed == 0 {
+				return opts.ExitEmpty
+			}
+			return 0
+		}
+	}
+	outfeed, err := buildFeed(opts, parsed, nil)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	now := time.Now()
-	outfeed := &feeds.Feed{
-		Title:       "Darling",
-		Description: "Your darlings, killfiled",
-		Created:     now,
-		// Link and Author are required by feeds
-		Link:   &feeds.Link{Href: ""},
-		Author: &feeds.Author{Name: "You"},
-	}
-	outfeed.Items = []*feeds.Item{}
+	if opts.OutputFile != "" {
+		if err := writeOutputFile(opts, outfeed); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		if err := printOutput(opts, outfeed, feedTokens); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(outfeed.Items) == 0 {
+		return opts.ExitEmpty
+	}
+	return 0
+}
+
+// newFilters builds the filters every item must pass. Relative times
+// are measured from now.
+func newFilters(opts Options, now time.Time) ([]filter.ItemFilter, error) {
+	blacklist, err := filter.NewRegexp(opts.Blacklist)
+	if err != nil {
+		return nil, err
+	}
+	whitelist, err := filter.NewRegexp(opts.Whitelist)
+	if err != nil {
+		return nil, err
+	}
+	wordMatch := filter.Or{Left: &filter.Not{Base: blacklist}, Right: whitelist}
 	filterList := []filter.ItemFilter{&wordMatch}
-	if sinceMatch != nil {
+	scoring := opts.scoring
+	if scoring == nil {
+		scoring, err = newScoring(opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if scoring != nil {
+		filterList = append(filterList, scoring)
+	}
+	if opts.model != nil {
+		filterList = append(filterList, &filter.Classifier{Model: opts.model, MinProb: opts.MinProb})
+	}
+	if opts.Since != "" {
+		sinceMatch, err := filter.NewSince(&opts.Since, now)
+		if err != nil {
+			return nil, err
+		}
 		filterList = append(filterList, sinceMatch)
 	}
-	// Optionally accept STDIN
-	stdinStat, err := os.Stdin.Stat()
+	length := filter.Length{
+		MinWords: opts.MinWords,
+		MaxWords: opts.MaxWords,
+		MinTime:  opts.MinReadingTime,
+		MaxTime:  opts.MaxReadingTime,
+	}
+	if opts.ReadingTime || length != (filter.Length{}) {
+		filterList = append(filterList, &length)
+	}
+	for _, tag := range opts.Tags {
+		filterList = append(filterList, tag)
+	}
+	return filterList, nil
+}
+
+// newScoring builds the scoring filter for opts, if there's one.
+func newScoring(opts Options) (*filter.WeightedTerms, error) {
+	if len(opts.Scores) == 0 {
+		return nil, nil
+	}
+	fields, err := filter.ParseFieldWeights(opts.ScoreFields)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	if stdinStat.Mode()&os.ModeCharDevice == 0 && stdinStat.Size() > 0 {
-		reader := bufio.NewReader(os.Stdin)
-		wg.Add(1)
-		go func(r *bufio.Reader, filters []filter.ItemFilter) {
-			if *limit > 0 {
-				filters = append(filters, &filter.Count{Limit: *limit})
-			}
-			defer wg.Done()
-			buf := new(bytes.Buffer)
-			buf.ReadFrom(r)
-			s := buf.String()
-			f, err := feed.ParseFromString(string(s))
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Unable to parse stdin: %s\n", err)
-			} else {
-				outfeed.Items = append(outfeed.Items, feed.ProcessItems(f.Items, filters)...)
-			}
-		}(reader, filterList)
+	scoring, err := filter.NewWeightedTerms(opts.Scores, fields, opts.MinScore)
+	if err != nil {
+		return nil, err
 	}
-	for _, token := range feedTokens {
-		if validateUrl(token) {
-			wg.Add(1)
-			go func(u string, filters []filter.ItemFilter) {
-				if *limit > 0 {
-					filters = append(filters, &filter.Count{Limit: *limit})
-				}
-				defer wg.Done()
-				f, err := feed.Fetch(u)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Unable to fetch %s: %s\n", u, err)
-				} else {
-					outfeed.Items = append(outfeed.Items, feed.ProcessItems(f.Items, filters)...)
-				}
-			}(token, filterList)
-		} else if validatePath(token) {
+	if opts.Explain {
+		scoring.Explain = os.Stderr
+	}
+	return scoring, nil
+}
+
+// loadScoring builds the scoring filter once, for all the runs of the
+// filters to share.
+func loadScoring(opts *Options) error {
+	scoring, err := newScoring(*opts)
+	if err != nil {
+		return err
+	}
+	opts.scoring = scoring
+	return nil
+}
+
+// loadModel loads the classifier model named by opts, if any.
+func loadModel(opts *Options) error {
+	if opts.Classifier == "" {
+		return nil
+	}
+	model, err := filter.LoadModel(opts.Classifier)
+	if err != nil {
+		return fmt.Errorf("Unable to load classifier: %s", err)
+	}
+	opts.model = model
+	return nil
+}
+
+// fetchAll fetches or reads every feed location concurrently. The token
+// "-" reads STDIN, and globs and directories read every file they name.
+// Locations which can't be read are reported and left out.
+func fetchAll(opts Options, feedTokens []string) []*gofeed.Feed {
+	var wg sync.WaitGroup
+	feedTokens = expandTokens(feedTokens)
+	parsed := make([][]*gofeed.Feed, len(feedTokens))
+	for i, token := range feedTokens {
+		if token == "-" || validateUrl(token) || validatePath(token) {
 			wg.Add(1)
-			go func(fp string, filters []filter.ItemFilter) {
-				if *limit > 0 {
-					filters = append(filters, &filter.Count{Limit: *limit})
-				}
+			go func(i int, token string) {
 				defer wg.Done()
-				file, err := os.Open(fp)
+				fs, err := fetchToken(opts, token)
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "Unable to read %s: %s\n", fp, err)
-					return
+					fmt.Fprintf(os.Stderr, "%s\n", err)
 				} else {
-					defer file.Close()
-					buf, err := ioutil.ReadAll(file)
-					if err != nil {
-						fmt.Fprintf(os.Stderr, "Unable to read %s: %s\n", fp, err)
-						return
-					}
-					f, err := feed.ParseFromString(string(buf))
-					if err != nil {
-						fmt.Fprintf(os.Stderr, "Unable to read %s: %s\n", fp, err)
-					} else {
-						outfeed.Items = append(outfeed.Items, feed.ProcessItems(f.Items, filters)...)
-					}
+					parsed[i] = fs
 				}
-			}(token, filterList)
+			}(i, token)
 		} else {
 			fmt.Fprintf(os.Stderr, "Unable to process %s as a feed location\n", token)
 		}
 		// TODO: Warning messages on bad tokens
 	}
 	wg.Wait()
+	saveRedirects(opts)
+
+	feeds := []*gofeed.Feed{}
+	for _, fs := range parsed {
+		feeds = append(feeds, fs...)
+	}
+	return feeds
+}
+
+var stdinOnce sync.Once
+
+// readStdin reads whatever has been piped in: one or more feed documents,
+// NDJSON items, or a list of feed locations separated by newlines or NUL
+// characters, which are then fetched in turn. Like files, it may be
+// compressed or an archive. STDIN can only be read
+// once, however many times "-" is given.
+func readStdin(opts Options) ([]*gofeed.Feed, error) {
+	var data []byte
+	var err error
+	stdinOnce.Do(func() {
+		data, err = ioutil.ReadAll(os.Stdin)
+	})
+	if err != nil {
+		return nil, err
+	}
+	docs, err := feed.Unpack(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) != 1 || opts.InputType == "ndjson" {
+		return parseStdin(opts, docs)
+	}
+	data = docs[0]
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+	if trimmed[0] == '<' {
+		return parseStdin(opts, docs)
+	}
+	separator := []byte("\n")
+	if bytes.IndexByte(data, 0) >= 0 {
+		separator = []byte{0}
+	}
+	locations := []string{}
+	for _, line := range bytes.Split(data, separator) {
+		location := string(bytes.TrimSpace(line))
+		if location != "" && location != "-" {
+			locations = append(locations, location)
+		}
+	}
+	return fetchAll(opts, locations), nil
+}
+
+// parseStdin parses the documents piped in, preparing their feeds as
+// fetchToken would. Feeds listed on STDIN are prepared as they're
+// fetched instead.
+func parseStdin(opts Options, docs [][]byte) ([]*gofeed.Feed, error) {
+	fs, err := parseInput(opts, docs)
+	if err != nil {
+		return nil, err
+	}
+	prepareFeeds(opts, "-", fs)
+	return fs, nil
+}
+
+// fetchToken fetches a URL or reads a path. URLs are always fetched as
+// feeds; paths are read according to opts.InputType.
+func fetchToken(opts Options, token string) ([]*gofeed.Feed, error) {
+	if token == "-" {
+		fs, err := readStdin(opts)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse stdin: %s", err)
+		}
+		return fs, nil
+	}
+	if validateUrl(token) {
+		f, err := feed.Fetch(token)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to fetch %s: %s", token, err)
+		}
+		f.FeedLink = token
+		fs := []*gofeed.Feed{f}
+		prepareFeeds(opts, token, fs)
+		return fs, nil
+	}
+	data, err := ioutil.ReadFile(token)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read %s: %s", token, err)
+	}
+	docs, err := feed.Unpack(data)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read %s: %s", token, err)
+	}
+	fs, err := parseInput(opts, docs)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read %s: %s", token, err)
+	}
+	prepareFeeds(opts, token, fs)
+	return fs, nil
+}
+
+// prepareFeeds does whatever opts asks to the items read from token
+// before they're filtered.
+func prepareFeeds(opts Options, token string, fs []*gofeed.Feed) {
+	// Items without GUIDs get one from their original link and title,
+	// so it doesn't change with whatever's done to them
+	for _, f := range fs {
+		for _, item := range f.Items {
+			item.GUID = feed.ItemID(item)
+		}
+	}
+	fullText(opts, token, fs)
+	cleanLinks(opts, fs)
+	rewriteFeeds(opts, fs)
+}
+
+// fullText replaces the content of the items read from token with the
+// articles they link to, if opts asks for that.
+func fullText(opts Options, token string, fs []*gofeed.Feed) {
+	wanted := false
+	for _, location := range opts.FullText {
+		wanted = wanted || location == token
+	}
+	if !wanted {
+		return
+	}
+	dir, err := stateDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return
+	}
+	cache := feed.NewArticleCache(filepath.Join(dir, "articles"))
+	for _, f := range fs {
+		feed.FullText(f, cache)
+	}
+}
+
+// cleanLinks normalizes the links in the items of fs, if opts asks for
+// that.
+func cleanLinks(opts Options, fs []*gofeed.Feed) {
+	if !opts.CleanLinks && len(opts.StripParams) == 0 && !opts.ResolveRedirects {
+		return
+	}
+	cleaner := &feed.LinkCleaner{Params: append(append([]string{}, feed.DefaultTrackingParams...), opts.StripParams...)}
+	cleaner.Redirects = opts.redirects
+	for _, f := range fs {
+		cleaner.Clean(f)
+	}
+}
+
+// loadRedirects opens the cache of resolved redirects, if opts asks for
+// them, for every feed in the run to share.
+func loadRedirects(opts *Options) error {
+	if !opts.ResolveRedirects {
+		return nil
+	}
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+	opts.redirects, err = feed.OpenRedirectCache(filepath.Join(dir, "redirects.json"))
+	return err
+}
+
+// saveRedirects writes back the redirects resolved while fetching.
+func saveRedirects(opts Options) {
+	if opts.redirects == nil {
+		return
+	}
+	if err := opts.redirects.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+	}
+}
+
+// parseInput parses unpacked documents, each holding one or more
+// concatenated feeds, or a stream of NDJSON items which may come from any
+// number of feeds.
+func parseInput(opts Options, docs [][]byte) ([]*gofeed.Feed, error) {
+	parsed := []*gofeed.Feed{}
+	for _, doc := range docs {
+		var fs []*gofeed.Feed
+		var err error
+		if opts.InputType == "ndjson" {
+			fs, err = feed.ParseNDJSON(bytes.NewReader(doc))
+		} else {
+			fs, err = feed.ParseDocuments(doc)
+		}
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, fs...)
+	}
+	return parsed, nil
+}
+
+// buildFeed filters the items of every parsed feed into a single output
+// feed, in reverse chronological order. If route is given, only items it
+// matches are included.
+func buildFeed(opts Options, parsed []*gofeed.Feed, route filter.ItemFilter) (*output.Feed, error) {
+	now := time.Now()
+	outfeed := &output.Feed{
+		Feed: feeds.Feed{
+			Title:       "Darling",
+			Description: "Your darlings, killfiled",
+			Created:     now,
+			// Link and Author are required by feeds
+			Link:   &feeds.Link{Href: ""},
+			Author: &feeds.Author{Name: "You"},
+		},
+	}
+	outfeed.Items = []*output.Item{}
+	var store *feed.SeenStore
+	if opts.Undated == "first-seen" {
+		dir, err := stateDir()
+		if err == nil {
+			store, err = feed.OpenSeenStore(filepath.Join(dir, "first-seen.json"))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	undated, err := feed.NewUndated(opts.Undated, store)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range parsed {
+		filters, err := newFilters(opts, now)
+		if err != nil {
+			return nil, err
+		}
+		if opts.feedModel != nil {
+			if model := opts.feedModel(f); model != nil {
+				filters = append(filters, &filter.Classifier{Model: model, MinProb: opts.MinProb})
+			}
+		}
+		if route != nil {
+			filters = append(filters, route)
+		}
+		if opts.Limit > 0 {
+			filters = append(filters, &filter.Count{Limit: opts.Limit})
+		}
+		items := feed.ProcessItems(f, filters, undated)
+		rewriteItems(opts, items)
+		if opts.PrefixSource && f.Title != "" {
+			for _, item := range items {
+				item.Title = f.Title + ": " + item.Title
+			}
+		}
+		outfeed.Items = append(outfeed.Items, items...)
+	}
 	// Reverse chronological order
 	sort.SliceStable(outfeed.Items, func(a, b int) bool {
 		return outfeed.Items[a].Created.After(outfeed.Items[b].Created)
 	})
+	if err := sanitize(opts, outfeed.Items); err != nil {
+		return nil, err
+	}
+	if opts.Summary.Length > 0 || opts.DropContent {
+		output.Summarize(outfeed.Items, opts.Summary, opts.DropContent)
+	}
+	if err := opts.Metadata.Apply(outfeed, parsed); err != nil {
+		return nil, err
+	}
+	if store != nil {
+		if err := store.Save(); err != nil {
+			return nil, err
+		}
+	}
+	return outfeed, nil
+}
 
-	var result string
-	if *outputType == "atom" {
-		result, err = output.FeedToAtom(outfeed)
-	} else {
-		result, err = output.FeedToRss(outfeed)
+// sanitize cleans the HTML in items as opts.Sanitize asks.
+func sanitize(opts Options, items []*output.Item) error {
+	policy := output.DefaultPolicy
+	if opts.SanitizePolicy != nil {
+		policy = *opts.SanitizePolicy
+	}
+	switch opts.Sanitize {
+	case "":
+	case "html":
+		output.Sanitize(items, policy, false)
+	case "text":
+		output.Sanitize(items, policy, true)
+	default:
+		return fmt.Errorf("Unknown sanitize mode %q; use 'html' or 'text'", opts.Sanitize)
 	}
+	return nil
+}
+
+// checkMetadata parses the templates for the output feed's metadata, and
+// each route's.
+func checkMetadata(opts Options) error {
+	if err := opts.Metadata.Check(); err != nil {
+		return err
+	}
+	for _, route := range opts.Routes {
+		if err := route.Metadata.Check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func render(opts Options, outfeed *output.Feed) (string, error) {
+	switch opts.OutputType {
+	case "atom":
+		return output.FeedToAtom(outfeed)
+	case "ndjson":
+		return output.FeedToNDJSON(outfeed)
+	}
+	return output.FeedToRss(outfeed)
+}
+
+func printOutput(opts Options, outfeed *output.Feed, feedTokens []string) error {
+	result, err := render(opts, outfeed)
 	if err != nil {
-		log.Fatal(err)
-	} else {
+		return err
+	}
+	unchanged := false
+	if opts.QuietIfUnchanged {
+		unchanged, err = checkUnchanged(outfeed, lastRunKey(opts, feedTokens))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to compare with previous run: %s\n", err)
+		}
+	}
+	if !unchanged {
 		fmt.Println(result)
 	}
+	return nil
+}
+
+// writeOutputFile replaces opts.OutputFile with the rendered feed, unless
+// the file already holds the same feed. Items dated at the time of the
+// run keep the dates they were first written with, and the comparison
+// borrows the existing file's build time, so a rebuild alone doesn't
+// count as a change.
+func writeOutputFile(opts Options, outfeed *output.Feed) error {
+	var previous []byte
+	var prior []*gofeed.Feed
+	if buf, err := ioutil.ReadFile(opts.OutputFile); err == nil {
+		previous = buf
+		prior = previousFeeds(opts, string(previous))
+		keepDates(outfeed, prior)
+	}
+	result, err := render(opts, outfeed)
+	if err != nil {
+		return err
+	}
+	if previous != nil {
+		if result+"\n" == string(previous) {
+			return nil
+		}
+		if built := buildTime(prior); built != nil {
+			stable := *outfeed
+			stable.Created = *built
+			stableResult, err := render(opts, &stable)
+			if err != nil {
+				return err
+			}
+			if stableResult+"\n" == string(previous) {
+				return nil
+			}
+		}
+	}
+	return output.WriteFile(opts.OutputFile, result+"\n")
+}
+
+// previousFeeds reads back a result darling wrote before, or returns nil
+// if it can't.
+func previousFeeds(opts Options, previous string) []*gofeed.Feed {
+	if opts.OutputType == "ndjson" {
+		fs, err := feed.ParseNDJSON(strings.NewReader(previous))
+		if err != nil {
+			return nil
+		}
+		return fs
+	}
+	f, err := feed.ParseFromString(previous)
+	if err != nil {
+		return nil
+	}
+	return []*gofeed.Feed{f}
+}
+
+// keepDates gives the items in outfeed which were dated at the time of
+// the run the dates they had in a previous result, if they were in it.
+func keepDates(outfeed *output.Feed, prior []*gofeed.Feed) {
+	dates := map[string]time.Time{}
+	for _, f := range prior {
+		for _, item := range f.Items {
+			if item.PublishedParsed != nil {
+				dates[item.GUID] = *item.PublishedParsed
+			} else if item.UpdatedParsed != nil {
+				dates[item.GUID] = *item.UpdatedParsed
+			}
+		}
+	}
+	for _, item := range outfeed.Items {
+		if date, found := dates[item.Id]; found && item.DatedNow {
+			item.Created = date
+		}
+	}
+	sort.SliceStable(outfeed.Items, func(a, b int) bool {
+		return outfeed.Items[a].Created.After(outfeed.Items[b].Created)
+	})
+}
+
+// buildTime is the feed-level timestamp darling wrote into a previous
+// result, if it can be found.
+func buildTime(prior []*gofeed.Feed) *time.Time {
+	if len(prior) != 1 {
+		return nil
+	}
+	f := prior[0]
+	if f.PublishedParsed != nil {
+		return f.PublishedParsed
+	}
+	return f.UpdatedParsed
 }
 
 func validateUrl(toTest string) bool {
@@ -153,3 +724,53 @@ func validatePath(toTest string) bool {
 	}
 	return res
 }
+
+// expandTokens replaces globs and directories among the feed locations
+// with the files they name.
+func expandTokens(feedTokens []string) []string {
+	expanded := []string{}
+	for _, token := range feedTokens {
+		if token == "-" || validateUrl(token) {
+			expanded = append(expanded, token)
+			continue
+		}
+		paths := []string{token}
+		if strings.ContainsAny(token, "*?[") {
+			if matches, err := filepath.Glob(filepath.Clean(token)); err == nil && len(matches) > 0 {
+				paths = matches
+			}
+		}
+		for _, path := range paths {
+			expanded = append(expanded, expandDir(path)...)
+		}
+	}
+	return expanded
+}
+
+// expandDir lists the files within a directory and its subdirectories,
+// skipping hidden ones. Anything other than a directory is returned as
+// it is.
+func expandDir(path string) []string {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return []string{path}
+	}
+	files := []string{}
+	filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to read %s: %s\n", p, err)
+			return nil
+		}
+		if p != path && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files
+}
@@ -0,0 +1,90 @@
+package darling
+
+import (
+	"github.com/gorilla/feeds"
+	"github.com/mmcdole/gofeed"
+	"github.com/snark/darling/pkg/feed"
+	"github.com/snark/darling/pkg/output"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteOutputFileUnchanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "darling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.rss")
+	created := time.Date(2019, 10, 12, 16, 25, 0, 0, time.UTC)
+	run := func(built time.Time) *output.Feed {
+		return &output.Feed{
+			Feed: feeds.Feed{Title: "Darling", Link: &feeds.Link{}, Created: built},
+			Items: []*output.Item{
+				{
+					// Dated at the time of each run
+					Item:     &feeds.Item{Id: "urn:darling:1", Title: "Undated", Link: &feeds.Link{Href: "https://example.com/1"}, Created: built},
+					DatedNow: true,
+				},
+				{
+					Item: &feeds.Item{Id: "https://example.com/2", Title: "An item", Link: &feeds.Link{Href: "https://example.com/2"}, Created: created},
+				},
+			},
+		}
+	}
+
+	for _, outputType := range []string{"rss", "atom", "ndjson"} {
+		t.Run(outputType, func(t *testing.T) {
+			opts := Options{OutputType: outputType, OutputFile: path}
+			if err := writeOutputFile(opts, run(time.Now())); err != nil {
+				t.Fatal(err)
+			}
+			// Backdate the file, so any rewrite would show
+			written := time.Now().Add(-time.Hour).Truncate(time.Second)
+			if err := os.Chtimes(path, written, written); err != nil {
+				t.Fatal(err)
+			}
+			before, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := writeOutputFile(opts, run(time.Now().Add(time.Minute))); err != nil {
+				t.Fatal(err)
+			}
+			after, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(after) != string(before) {
+				t.Errorf("file content changed:\n%s\n%s", before, after)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !info.ModTime().Equal(written) {
+				t.Errorf("file was rewritten at %s", info.ModTime())
+			}
+		})
+	}
+}
+
+func TestPrepareFeedsKeepsIDs(t *testing.T) {
+	rule, err := ParseRewrite(`title=s/^\[Ad\] //`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{CleanLinks: true, Rewrites: []Rewrite{rule}}
+	original := gofeed.Item{Title: "[Ad] A post", Link: "https://example.com/post?utm_source=rss"}
+	item := original
+	prepareFeeds(opts, "-", []*gofeed.Feed{{Items: []*gofeed.Item{&item}}})
+	if item.Link != "https://example.com/post" || item.Title != "A post" {
+		t.Fatalf("item wasn't prepared: %q at %q", item.Title, item.Link)
+	}
+	if want := feed.ItemID(&original); feed.ItemID(&item) != want {
+		t.Errorf("got id %s, want %s from the item as it was", feed.ItemID(&item), want)
+	}
+}
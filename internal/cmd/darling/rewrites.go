@@ -0,0 +1,68 @@
+package darling
+
+import (
+	"github.com/mmcdole/gofeed"
+	"github.com/snark/darling/pkg/feed"
+	"github.com/snark/darling/pkg/output"
+)
+
+// Rewrite applies a rewrite rule to the items of every feed, or of one.
+type Rewrite struct {
+	*feed.Rewrite
+	// Source, if set, limits the rule to the feed with this URL, link or
+	// title.
+	Source string
+	// After rewrites the items which pass the filters, rather than
+	// rewriting them all before filtering.
+	After bool
+}
+
+// ParseRewrite reads a rule for every feed given as
+// FIELD=s/PATTERN/REPLACEMENT/ on the command line; see feed.ParseRewrite.
+func ParseRewrite(spec string) (Rewrite, error) {
+	rule, err := feed.ParseRewrite(spec)
+	if err != nil {
+		return Rewrite{}, err
+	}
+	return Rewrite{Rewrite: rule}, nil
+}
+
+func (rule Rewrite) appliesTo(url, link, title string) bool {
+	return rule.Source == "" || rule.Source == url || rule.Source == link || rule.Source == title
+}
+
+// rewriteFeeds applies the rules which run before filtering to the items
+// of fs.
+func rewriteFeeds(opts Options, fs []*gofeed.Feed) {
+	for _, rule := range opts.Rewrites {
+		if rule.After {
+			continue
+		}
+		for _, f := range fs {
+			if !rule.appliesTo(f.FeedLink, f.Link, f.Title) {
+				continue
+			}
+			for _, item := range f.Items {
+				rule.Item(item)
+			}
+		}
+	}
+}
+
+// rewriteItems applies the rules which run after filtering to items.
+func rewriteItems(opts Options, items []*output.Item) {
+	for _, rule := range opts.Rewrites {
+		if !rule.After {
+			continue
+		}
+		for _, item := range items {
+			source := item.Source
+			if source == nil {
+				source = &output.Source{}
+			}
+			if rule.appliesTo(source.URL, source.Link, source.Title) {
+				rule.OutputItem(item.Item)
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package darling
+
+import (
+	"fmt"
+	"github.com/mmcdole/gofeed"
+	"github.com/snark/darling/pkg/filter"
+	"github.com/snark/darling/pkg/output"
+	"path/filepath"
+	"strings"
+)
+
+// Route sends the items which match it to an output file of its own.
+type Route struct {
+	OutputFile string
+	// Match selects the route's items. A nil Match makes this a
+	// catch-all route, for items no other route takes.
+	Match filter.ItemFilter
+	// Terms are what Match was built from
+	Terms []string
+	// Metadata overrides the output feed's metadata for this route
+	Metadata output.Metadata
+}
+
+// ParseRoute reads a route given as FILE=TERM,TERM,... on the command
+// line. Items mentioning any of the terms are sent to FILE, unless they
+// also mention a term prefixed with '-'. A bare FILE takes everything
+// the other routes don't.
+func ParseRoute(spec string) (Route, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) == 1 {
+		return NewRoute(parts[0], nil)
+	}
+	return NewRoute(parts[0], strings.Split(parts[1], ","))
+}
+
+// NewRoute builds a route sending items which mention any of terms to
+// path. Terms prefixed with '-' exclude items instead, and a route with
+// no terms at all is a catch-all.
+func NewRoute(path string, terms []string) (Route, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return Route{}, fmt.Errorf("Route for %s has no output file", strings.Join(terms, ","))
+	}
+	match, err := termsFilter(terms)
+	if err != nil {
+		return Route{}, err
+	}
+	return Route{OutputFile: path, Match: match, Terms: terms}, nil
+}
+
+// termsFilter matches items which mention any of terms, unless they also
+// mention one of those prefixed with '-'. Without terms, it's nil.
+func termsFilter(terms []string) (filter.ItemFilter, error) {
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	include := []string{}
+	exclude := []string{}
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		if strings.HasPrefix(term, "-") {
+			exclude = append(exclude, strings.TrimPrefix(term, "-"))
+		} else if term != "" {
+			include = append(include, term)
+		}
+	}
+	var match filter.ItemFilter = &filter.True{}
+	if len(include) > 0 {
+		included, err := filter.NewRegexp(include)
+		if err != nil {
+			return nil, err
+		}
+		match = included
+	}
+	if len(exclude) > 0 {
+		excluded, err := filter.NewRegexp(exclude)
+		if err != nil {
+			return nil, err
+		}
+		match = &filter.And{Left: match, Right: &filter.Not{Base: excluded}}
+	}
+	return match, nil
+}
+
+// routeFilters works out the predicate for each route, in order. The
+// catch-all routes match whatever none of the others do.
+func routeFilters(routes []Route) []filter.ItemFilter {
+	var others filter.ItemFilter
+	for _, route := range routes {
+		if route.Match == nil {
+			continue
+		}
+		if others == nil {
+			others = route.Match
+		} else {
+			others = &filter.Or{Left: others, Right: route.Match}
+		}
+	}
+	predicates := make([]filter.ItemFilter, len(routes))
+	for i, route := range routes {
+		switch {
+		case route.Match != nil:
+			predicates[i] = route.Match
+		case others != nil:
+			predicates[i] = &filter.Not{Base: others}
+		default:
+			predicates[i] = &filter.True{}
+		}
+	}
+	return predicates
+}
+
+// outputTypeFor picks an output type from a file's extension, falling
+// back to the one requested on the command line.
+func outputTypeFor(path string, fallback string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".atom":
+		return "atom"
+	case ".rss":
+		return "rss"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	}
+	return fallback
+}
+
+// writeRoutes builds and writes the output for every route from a single
+// set of parsed feeds, returning how many items were routed in total.
+// An item may be sent to as many routes as it matches.
+func writeRoutes(opts Options, parsed []*gofeed.Feed) (int, error) {
+	routed := 0
+	for i, match := range routeFilters(opts.Routes) {
+		routeOpts := opts
+		routeOpts.OutputFile = opts.Routes[i].OutputFile
+		routeOpts.OutputType = outputTypeFor(routeOpts.OutputFile, opts.OutputType)
+		routeOpts.Metadata = opts.Metadata.Merge(opts.Routes[i].Metadata)
+		outfeed, err := buildFeed(routeOpts, parsed, match)
+		if err != nil {
+			return routed, err
+		}
+		if err := writeOutputFile(routeOpts, outfeed); err != nil {
+			return routed, err
+		}
+		routed += len(outfeed.Items)
+	}
+	return routed, nil
+}
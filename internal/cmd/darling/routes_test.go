@@ -0,0 +1,165 @@
+package darling
+
+import (
+	"github.com/mmcdole/gofeed"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func routedItems() []*gofeed.Item {
+	published := time.Date(2019, 10, 12, 16, 25, 0, 0, time.UTC)
+	items := []*gofeed.Item{}
+	for _, title := range []string{
+		"Rust and Go, compared",
+		"Writing Go in anger",
+		"Rust without the borrow checker",
+		"A history of COBOL",
+	} {
+		items = append(items, &gofeed.Item{Title: title, Link: "https://example.com/" + title, PublishedParsed: &published})
+	}
+	return items
+}
+
+func TestParseRoute(t *testing.T) {
+	route, err := ParseRoute("go.rss=golang, go ,-rust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if route.OutputFile != "go.rss" || route.Match == nil {
+		t.Errorf("got route to %s", route.OutputFile)
+	}
+	rest, err := ParseRoute("rest.atom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rest.OutputFile != "rest.atom" || rest.Match != nil {
+		t.Errorf("a bare file didn't give a catch-all route")
+	}
+	if _, err := ParseRoute("=go"); err == nil {
+		t.Errorf("did not return an error for a route without a file")
+	}
+}
+
+func TestRouteFilters(t *testing.T) {
+	route := func(spec string) Route {
+		r, err := ParseRoute(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	var tests = []struct {
+		name   string
+		routes []Route
+		// want lists the titles each route takes, by their first words
+		want []string
+	}{
+		{
+			"overlapping",
+			[]Route{route("go.rss=go"), route("rust.rss=rust")},
+			[]string{"Rust Writing", "Rust Rust"},
+		},
+		{
+			"exclusion",
+			[]Route{route("go.rss=go,-rust")},
+			[]string{"Writing"},
+		},
+		{
+			"catch-all",
+			[]Route{route("go.rss=go"), route("rest.rss"), route("rust.rss=rust")},
+			[]string{"Rust Writing", "A", "Rust Rust"},
+		},
+		{
+			"only a catch-all",
+			[]Route{route("all.rss")},
+			[]string{"Rust Writing Rust A"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, match := range routeFilters(tt.routes) {
+				got := []string{}
+				for _, item := range routedItems() {
+					if match.Match(*item) {
+						got = append(got, strings.Fields(item.Title)[0])
+					}
+				}
+				if strings.Join(got, " ") != tt.want[i] {
+					t.Errorf("%s got %v, want %s", tt.routes[i].OutputFile, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestOutputTypeFor(t *testing.T) {
+	var tests = []struct {
+		path string
+		want string
+	}{
+		{"out.atom", "atom"},
+		{"out.RSS", "rss"},
+		{"out.ndjson", "ndjson"},
+		{"out.jsonl", "ndjson"},
+		{"out.xml", "atom"},
+		{"out", "atom"},
+	}
+	for _, tt := range tests {
+		if got := outputTypeFor(tt.path, "atom"); got != tt.want {
+			t.Errorf("outputTypeFor(%q) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestWriteRoutes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "darling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	routes := []Route{}
+	for _, spec := range []string{"go.rss=go", "rust.atom=rust", "rest.ndjson"} {
+		route, err := ParseRoute(filepath.Join(dir, spec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		routes = append(routes, route)
+	}
+	routes[1].Metadata.Title = "Rust news"
+	opts := Options{Routes: routes, OutputType: "rss", Undated: "drop"}
+	parsed := []*gofeed.Feed{{Title: "Example", Items: routedItems()}}
+	routed, err := writeRoutes(opts, parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The item about both Rust and Go is counted for each route
+	if routed != 5 {
+		t.Errorf("routed %d items, want 5", routed)
+	}
+	var tests = []struct {
+		file  string
+		count string
+		n     int
+		want  string
+	}{
+		{"go.rss", "<item>", 2, "<rss"},
+		{"rust.atom", "<entry>", 2, "<title>Rust news</title>"},
+		{"rest.ndjson", "\n", 1, `"title":"A history of COBOL"`},
+	}
+	for _, tt := range tests {
+		buf, err := ioutil.ReadFile(filepath.Join(dir, tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(buf), tt.count); n != tt.n {
+			t.Errorf("%s has %d items, want %d", tt.file, n, tt.n)
+		}
+		if !strings.Contains(string(buf), tt.want) {
+			t.Errorf("%s is missing %s", tt.file, tt.want)
+		}
+	}
+}
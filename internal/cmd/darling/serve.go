@@ -0,0 +1,314 @@
+package darling
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/mmcdole/gofeed"
+	"github.com/snark/darling/pkg/filter"
+	"github.com/snark/darling/pkg/output"
+	"html"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long an item can go unserved before feedback on it is refused
+const servedExpiry = 30 * 24 * time.Hour
+
+// server serves the filtered feed, and any routes, over HTTP. It keeps a
+// model for each feed, trained by feedback on that feed's items.
+type server struct {
+	opts     Options
+	interval time.Duration
+	// Feedback adds feedback links to every item served, under baseURL
+	feedback bool
+	baseURL  string
+
+	// polling is held while the sources are polled, and mu while the
+	// rest is used
+	polling sync.Mutex
+	sources []*watchedSource
+	mu      sync.Mutex
+	files   *termFiles
+	items   map[string]*servedItem
+	models  map[string]*filter.Model
+}
+
+// servedItem is an item which feedback can be given on.
+type servedItem struct {
+	item  output.Item
+	seen  time.Time
+	voted bool
+}
+
+// Serve serves the feeds at addr until the process is killed. The
+// filtered feed is at /, and each route at its file name. Feeds are
+// polled when a request comes in, but never more often than Watch would
+// poll them. With feedback, items link to /feedback/<hash>?v=up or
+// ?v=down under baseURL, where darling is reached. A vote posted there
+// trains a model for the item's feed, which then filters it with
+// opts.MinProb.
+func Serve(opts Options, interval time.Duration, addr string, baseURL string, feedback bool, feedTokens []string) int {
+	if feedback && baseURL == "" {
+		fmt.Fprintf(os.Stderr, "Feedback links need --base-url, the URL darling is served at\n")
+		return 2
+	}
+	s, err := newServer(opts, interval, baseURL, feedback, feedTokens)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 2
+	}
+	fmt.Fprintf(os.Stderr, "Serving on %s\n", addr)
+	if err := http.ListenAndServe(addr, s.handler()); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func newServer(opts Options, interval time.Duration, baseURL string, feedback bool, feedTokens []string) (*server, error) {
+	s := &server{
+		opts:     opts,
+		interval: interval,
+		feedback: feedback,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		items:    map[string]*servedItem{},
+		models:   map[string]*filter.Model{},
+	}
+	sources, files, err := newSources(&s.opts, feedTokens)
+	if err != nil {
+		return nil, err
+	}
+	s.sources = sources
+	s.files = files
+	s.opts.feedModel = s.model
+	return s, nil
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feedback/", s.serveFeedback)
+	mux.HandleFunc("/", s.serveFeed)
+	return mux
+}
+
+// serveFeed serves the filtered feed at /, and each route at its file
+// name.
+func (s *server) serveFeed(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	s.polling.Lock()
+	_, parsed, _ := pollAll(s.opts, s.interval, s.sources, now)
+	s.polling.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.files.reload(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+	}
+	opts := s.files.apply(s.opts)
+	var route filter.ItemFilter
+	if r.URL.Path != "/" {
+		found := false
+		for i, match := range routeFilters(opts.Routes) {
+			if "/"+filepath.Base(opts.Routes[i].OutputFile) == r.URL.Path {
+				opts.OutputType = outputTypeFor(opts.Routes[i].OutputFile, opts.OutputType)
+				opts.Metadata = opts.Metadata.Merge(opts.Routes[i].Metadata)
+				route = match
+				found = true
+				break
+			}
+		}
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	if opts.scoring != nil {
+		opts.scoring.Forget()
+	}
+	outfeed, err := buildFeed(opts, parsed, route)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, item := range outfeed.Items {
+		hash := feedbackHash(item)
+		if served, found := s.items[hash]; found {
+			served.seen = now
+		} else {
+			// Keep a copy without the feedback links
+			original := *item.Item
+			s.items[hash] = &servedItem{item: output.Item{Item: &original, Source: item.Source}, seen: now}
+		}
+		if s.feedback {
+			addFeedbackLinks(item, s.baseURL+"/feedback/"+hash)
+		}
+	}
+	for hash, served := range s.items {
+		if now.Sub(served.seen) > servedExpiry {
+			delete(s.items, hash)
+		}
+	}
+
+	result, err := render(opts, outfeed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	switch opts.OutputType {
+	case "atom":
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	default:
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	}
+	fmt.Fprintln(w, result)
+}
+
+// serveFeedback records a vote on an item posted to /feedback/<hash>
+// with v=up or v=down. The links in items get a page asking to confirm
+// the vote, since feed readers and link previews may follow them. Only
+// the first vote on each item counts.
+func (s *server) serveFeedback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	hash := strings.TrimPrefix(r.URL.Path, "/feedback/")
+	vote := r.FormValue("v")
+	if vote != "up" && vote != "down" {
+		http.Error(w, "Vote with v=up or v=down", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	served, found := s.items[hash]
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method == "GET" {
+		confirmVote(w, served.item.Title, vote)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if served.voted {
+		fmt.Fprintf(w, "Already noted: %s\n", served.item.Title)
+		return
+	}
+	key := ""
+	if source := served.item.Source; source != nil {
+		key = feedKey(source.URL, source.Link, source.Title)
+	}
+	model := s.feedbackModel(key)
+	model.Train(gofeed.Item{
+		Title:       served.item.Title,
+		Description: served.item.Description,
+		Content:     served.item.Content,
+	}, vote == "up")
+	path, err := modelPath(key)
+	if err == nil {
+		err = model.Save(path)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	served.voted = true
+	if vote == "up" {
+		fmt.Fprintf(w, "Noted: you liked %s\n", served.item.Title)
+	} else {
+		fmt.Fprintf(w, "Noted: you didn't like %s\n", served.item.Title)
+	}
+}
+
+// confirmVote serves a page with a button that posts the vote.
+func confirmVote(w http.ResponseWriter, title string, vote string) {
+	label := "👍 I liked"
+	if vote == "down" {
+		label = "👎 I didn't like"
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<title>Feedback</title>
+<form method="post"><input type="hidden" name="v" value="%s"><button>%s %s</button></form>
+`, vote, label, html.EscapeString(title))
+}
+
+// model is the feedback model for a feed, once it has been told about
+// both an item liked and one disliked. The caller holds s.mu.
+func (s *server) model(f *gofeed.Feed) *filter.Model {
+	model := s.feedbackModel(feedKey(f.FeedLink, f.Link, f.Title))
+	if model.Liked.Items == 0 || model.Disliked.Items == 0 {
+		return nil
+	}
+	return model
+}
+
+// feedbackModel is the feedback model for the feed with the given key,
+// loaded from where it's kept if need be. The caller holds s.mu.
+func (s *server) feedbackModel(key string) *filter.Model {
+	model, found := s.models[key]
+	if !found {
+		path, err := modelPath(key)
+		if err == nil {
+			model, err = filter.LoadModel(path)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+		}
+		if model == nil {
+			model = filter.NewModel()
+		}
+		s.models[key] = model
+	}
+	return model
+}
+
+// feedKey identifies a feed by the first it has of its URL, its link
+// and its title, since feeds read from files may not know their URL.
+func feedKey(url, link, title string) string {
+	if url != "" {
+		return url
+	}
+	if link != "" {
+		return link
+	}
+	return title
+}
+
+// modelPath is where the feedback model for a feed is kept.
+func modelPath(key string) (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dir, "models", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// feedbackHash identifies an item in feedback URLs.
+func feedbackHash(item *output.Item) string {
+	feedURL := ""
+	if item.Source != nil {
+		feedURL = item.Source.URL
+	}
+	sum := sha256.Sum256([]byte(feedURL + "\x00" + item.Id))
+	return hex.EncodeToString(sum[:8])
+}
+
+// addFeedbackLinks appends links for voting on the item to its
+// description and content.
+func addFeedbackLinks(item *output.Item, feedbackURL string) {
+	links := fmt.Sprintf(`<p><a href="%s?v=up">👍</a> <a href="%s?v=down">👎</a></p>`,
+		html.EscapeString(feedbackURL), html.EscapeString(feedbackURL))
+	item.Description += links
+	if item.Content != "" {
+		item.Content += links
+	}
+}
@@ -0,0 +1,160 @@
+package darling
+
+import (
+	"github.com/gorilla/feeds"
+	"github.com/snark/darling/pkg/output"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testServer(t *testing.T) (*server, *httptest.Server, func()) {
+	dir, err := ioutil.TempDir("", "darling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := os.Getenv("XDG_CACHE_HOME")
+	os.Setenv("XDG_CACHE_HOME", dir)
+	route, err := NewRoute("bash.atom", []string{"bash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Routes: []Route{route}, MinProb: 0.5}
+	s, err := newServer(opts, time.Hour, "https://example.com/darling/", true, []string{"../../../testdata/lobste.rs.rss"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s.handler())
+	return s, ts, func() {
+		ts.Close()
+		os.Setenv("XDG_CACHE_HOME", cache)
+		os.RemoveAll(dir)
+	}
+}
+
+func get(t *testing.T, url string) (int, string) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestServeRouting(t *testing.T) {
+	_, ts, cleanup := testServer(t)
+	defer cleanup()
+	var tests = []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{"/", http.StatusOK, "<rss"},
+		{"/bash.atom", http.StatusOK, "<feed"},
+		{"/elsewhere.rss", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		status, body := get(t, ts.URL+tt.path)
+		if status != tt.status {
+			t.Errorf("%s: got status %d, want %d", tt.path, status, tt.status)
+		}
+		if !strings.Contains(body, tt.want) {
+			t.Errorf("%s: response doesn't contain %s", tt.path, tt.want)
+		}
+	}
+	_, body := get(t, ts.URL+"/bash.atom")
+	if strings.Count(body, "<entry>") != 1 {
+		t.Errorf("route served %d items, want 1", strings.Count(body, "<entry>"))
+	}
+}
+
+func TestFeedbackHash(t *testing.T) {
+	hash := func(source, id string) string {
+		return feedbackHash(&output.Item{Item: &feeds.Item{Id: id}, Source: &output.Source{URL: source}})
+	}
+	if hash("https://a.example.com/", "1") != hash("https://a.example.com/", "1") {
+		t.Errorf("hash isn't stable")
+	}
+	if hash("https://a.example.com/", "1") == hash("https://b.example.com/", "1") {
+		t.Errorf("hash doesn't depend on the item's feed")
+	}
+	if hash("https://a.example.com/", "1") == hash("https://a.example.com/", "2") {
+		t.Errorf("hash doesn't depend on the item's id")
+	}
+	if len(hash("", "1")) != 16 {
+		t.Errorf("got hash %s", hash("", "1"))
+	}
+}
+
+func TestServeFeedback(t *testing.T) {
+	s, ts, cleanup := testServer(t)
+	defer cleanup()
+	_, body := get(t, ts.URL+"/")
+	links := regexp.MustCompile(`https://example\.com/darling/feedback/([0-9a-f]+)\?v=up`).FindAllStringSubmatch(body, -1)
+	if len(links) == 0 {
+		t.Fatalf("served feed has no feedback links")
+	}
+	hash := links[0][1]
+	voteURL := ts.URL + "/feedback/" + hash
+
+	// Following the link only asks for confirmation
+	status, body := get(t, voteURL+"?v=up")
+	if status != http.StatusOK || !strings.Contains(body, `<form method="post">`) {
+		t.Errorf("got %d for the feedback link: %s", status, body)
+	}
+	if s.items[hash].voted {
+		t.Fatalf("following the feedback link recorded a vote")
+	}
+
+	post := func(hash, vote string) int {
+		resp, err := http.PostForm(ts.URL+"/feedback/"+hash, url.Values{"v": {vote}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if status := post(hash, "up"); status != http.StatusOK {
+		t.Fatalf("got %d for a vote", status)
+	}
+	if !s.items[hash].voted {
+		t.Errorf("vote wasn't recorded")
+	}
+	key := feedKey(s.items[hash].item.Source.URL, s.items[hash].item.Source.Link, s.items[hash].item.Source.Title)
+	if model := s.models[key]; model == nil || model.Liked.Items != 1 {
+		t.Errorf("vote didn't train the feed's model")
+	}
+	post(hash, "down")
+	if model := s.models[key]; model.Disliked.Items != 0 {
+		t.Errorf("a second vote on the item counted")
+	}
+	if path, err := modelPath(key); err != nil {
+		t.Error(err)
+	} else if _, err := os.Stat(path); err != nil {
+		t.Errorf("model wasn't saved: %s", err)
+	}
+
+	if status := post(hash, "sideways"); status != http.StatusBadRequest {
+		t.Errorf("got %d for a bad vote", status)
+	}
+	if status := post("0000000000000000", "up"); status != http.StatusNotFound {
+		t.Errorf("got %d for an unknown item", status)
+	}
+}
+
+func TestNewServerBadSanitize(t *testing.T) {
+	opts := Options{Sanitize: "bogus"}
+	if _, err := newServer(opts, time.Hour, "", false, []string{"../../../testdata/lobste.rs.rss"}); err == nil {
+		t.Errorf("did not return an error for an unknown sanitize mode")
+	}
+}
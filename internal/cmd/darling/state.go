@@ -0,0 +1,83 @@
+package darling
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/snark/darling/pkg/output"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stateDir is where darling keeps what it remembers between runs.
+func stateDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "darling"), nil
+}
+
+// lastRunKey identifies an invocation, so that runs with different
+// feeds or filters don't compare themselves against each other.
+func lastRunKey(opts Options, feedTokens []string) string {
+	parts := []string{
+		strings.Join(opts.Blacklist, ","),
+		strings.Join(opts.Whitelist, ","),
+		opts.Since,
+		fmt.Sprint(opts.Limit),
+		opts.OutputType,
+		opts.InputType,
+		opts.Undated,
+		strings.Join(opts.Scores, ","),
+		strings.Join(opts.ScoreFields, ","),
+		fmt.Sprint(opts.MinScore),
+		opts.Classifier,
+		fmt.Sprint(opts.MinProb),
+		opts.Sanitize,
+		fmt.Sprint(opts.CleanLinks, opts.ResolveRedirects),
+		strings.Join(opts.StripParams, ","),
+		strings.Join(feedTokens, ","),
+	}
+	parts = append(parts, fmt.Sprint(opts.Summary, opts.DropContent))
+	parts = append(parts, fmt.Sprint(opts.MinWords, opts.MaxWords, opts.MinReadingTime, opts.MaxReadingTime, opts.ReadingTime))
+	for _, rule := range opts.Rewrites {
+		parts = append(parts, fmt.Sprint(rule.Rewrite, rule.Source, rule.After))
+	}
+	for _, tag := range opts.Tags {
+		parts = append(parts, tag.Rule)
+	}
+	for _, route := range opts.Routes {
+		parts = append(parts, fmt.Sprint(route.OutputFile, route.Terms, route.Metadata))
+	}
+	parts = append(parts, fmt.Sprint(opts.PrefixSource, opts.Metadata))
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// checkUnchanged reports whether outfeed matches the result recorded for
+// key by the previous run, recording outfeed's fingerprint for the next.
+func checkUnchanged(outfeed *output.Feed, key string) (bool, error) {
+	fingerprint, err := output.Fingerprint(outfeed)
+	if err != nil {
+		return false, err
+	}
+	dir, err := stateDir()
+	if err != nil {
+		return false, err
+	}
+	path := filepath.Join(dir, "last-"+key)
+	previous, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if string(previous) == fingerprint {
+		return true, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, err
+	}
+	return false, ioutil.WriteFile(path, []byte(fingerprint), 0644)
+}
@@ -0,0 +1,99 @@
+package darling
+
+import (
+	"github.com/gorilla/feeds"
+	"github.com/snark/darling/pkg/filter"
+	"github.com/snark/darling/pkg/output"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLastRunKey(t *testing.T) {
+	tokens := []string{"https://example.com/feed.xml"}
+	base := Options{Blacklist: []string{"crypto"}, OutputType: "rss"}
+	key := lastRunKey(base, tokens)
+	if key != lastRunKey(base, tokens) {
+		t.Fatalf("key isn't stable")
+	}
+	if key == lastRunKey(base, []string{"https://example.com/other.xml"}) {
+		t.Errorf("key doesn't depend on the feeds")
+	}
+
+	tag, err := NewTag("go", "", []string{"golang"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewTag("go", "title", []string{"golang"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	route, err := NewRoute("go.rss", []string{"golang"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	variants := map[string]func(opts *Options){
+		"tags":          func(opts *Options) { opts.Tags = []*filter.Tag{tag} },
+		"routes":        func(opts *Options) { opts.Routes = []Route{route} },
+		"prefix-source": func(opts *Options) { opts.PrefixSource = true },
+		"metadata":      func(opts *Options) { opts.Metadata.Title = "Go" },
+	}
+	seen := map[string]string{key: "base"}
+	for name, change := range variants {
+		opts := base
+		change(&opts)
+		changed := lastRunKey(opts, tokens)
+		if previous, ok := seen[changed]; ok {
+			t.Errorf("%s gives the same key as %s", name, previous)
+		}
+		seen[changed] = name
+	}
+	withTag := base
+	withTag.Tags = []*filter.Tag{tag}
+	withOther := base
+	withOther.Tags = []*filter.Tag{other}
+	if lastRunKey(withTag, tokens) == lastRunKey(withOther, tokens) {
+		t.Errorf("key doesn't depend on a tag's field")
+	}
+}
+
+func TestCheckUnchanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "darling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cache := os.Getenv("XDG_CACHE_HOME")
+	os.Setenv("XDG_CACHE_HOME", dir)
+	defer os.Setenv("XDG_CACHE_HOME", cache)
+
+	run := func(title string) *output.Feed {
+		return &output.Feed{
+			Feed: feeds.Feed{Title: "Darling", Link: &feeds.Link{}, Created: time.Now()},
+			Items: []*output.Item{{
+				Item:     &feeds.Item{Title: title, Link: &feeds.Link{Href: "https://example.com/"}, Created: time.Now()},
+				DatedNow: true,
+			}},
+		}
+	}
+	for i, tt := range []struct {
+		title     string
+		key       string
+		unchanged bool
+	}{
+		{"First", "a", false},
+		{"First", "a", true},
+		{"First", "b", false},
+		{"Second", "a", false},
+		{"Second", "a", true},
+	} {
+		unchanged, err := checkUnchanged(run(tt.title), tt.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unchanged != tt.unchanged {
+			t.Errorf("run %d: got unchanged %v, want %v", i, unchanged, tt.unchanged)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package darling
+
+import (
+	"fmt"
+	"github.com/snark/darling/pkg/filter"
+	"strings"
+)
+
+// ParseTag reads a tagging rule given as CATEGORY=TERM,TERM,... on the
+// command line, or CATEGORY@FIELD=TERM,... to look in a single field.
+// Items mentioning any of the terms are given the category, unless they
+// also mention a term prefixed with '-'.
+func ParseTag(spec string) (*filter.Tag, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("Tag %q should look like CATEGORY=TERM,TERM", spec)
+	}
+	category, field := parts[0], ""
+	if i := strings.LastIndex(category, "@"); i >= 0 {
+		category, field = category[:i], category[i+1:]
+	}
+	return NewTag(category, field, strings.Split(parts[1], ","))
+}
+
+// NewTag builds a rule giving category to the items which mention any of
+// terms, in field if one is given. Terms prefixed with '-' exclude items
+// instead, as in routes.
+func NewTag(category, field string, terms []string) (*filter.Tag, error) {
+	category = strings.TrimSpace(category)
+	if category == "" {
+		return nil, fmt.Errorf("Tag for %s has no category", strings.Join(terms, ","))
+	}
+	given := []string{}
+	for _, term := range terms {
+		if strings.TrimSpace(term) != "" {
+			given = append(given, term)
+		}
+	}
+	if len(given) == 0 {
+		return nil, fmt.Errorf("Tag %s has no terms", category)
+	}
+	match, err := termsFilter(given)
+	if err != nil {
+		return nil, err
+	}
+	if field = strings.TrimSpace(field); field != "" {
+		match, err = filter.NewField(field, match)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rule := fmt.Sprintf("%s@%s=%s", category, field, strings.Join(given, ","))
+	return &filter.Tag{Category: category, Predicate: match, Rule: rule}, nil
+}
@@ -0,0 +1,121 @@
+package darling
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/snark/darling/pkg/feed"
+	"github.com/snark/darling/pkg/filter"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// termFile is a list of blacklist or whitelist terms kept in a file or
+// at a URL, along with what's needed to tell when it changes.
+type termFile struct {
+	location string
+	terms    []string
+	cache    feed.CacheInfo
+	modTime  time.Time
+}
+
+// reload reads the list again if it has changed, reporting whether it
+// did. On failure the terms already read are kept.
+func (f *termFile) reload() (bool, error) {
+	if validateUrl(f.location) {
+		body, info, err := feed.GetIfChanged(f.location, f.cache)
+		if err != nil {
+			return false, fmt.Errorf("Unable to fetch %s: %s", f.location, err)
+		}
+		f.cache = info
+		if body == nil {
+			return false, nil
+		}
+		return f.parse(body)
+	}
+	stat, err := os.Stat(f.location)
+	if err != nil {
+		return false, fmt.Errorf("Unable to read %s: %s", f.location, err)
+	}
+	if stat.ModTime().Equal(f.modTime) && f.terms != nil {
+		return false, nil
+	}
+	body, err := ioutil.ReadFile(f.location)
+	if err != nil {
+		return false, fmt.Errorf("Unable to read %s: %s", f.location, err)
+	}
+	f.modTime = stat.ModTime()
+	return f.parse(body)
+}
+
+func (f *termFile) parse(body []byte) (bool, error) {
+	terms, err := filter.ReadTerms(bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("Unable to read %s: %s", f.location, err)
+	}
+	changed := f.terms == nil || !equalTerms(terms, f.terms)
+	f.terms = terms
+	return changed, nil
+}
+
+func equalTerms(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// termFiles are the lists named by --blacklist-file and
+// --whitelist-file.
+type termFiles struct {
+	blacklist []*termFile
+	whitelist []*termFile
+}
+
+func newTermFiles(opts Options) *termFiles {
+	files := &termFiles{}
+	for _, location := range opts.BlacklistFiles {
+		files.blacklist = append(files.blacklist, &termFile{location: location})
+	}
+	for _, location := range opts.WhitelistFiles {
+		files.whitelist = append(files.whitelist, &termFile{location: location})
+	}
+	return files
+}
+
+// reload reloads every list, reporting whether any of them changed.
+// A list which can't be read doesn't stop the others from reloading.
+func (files *termFiles) reload() (bool, error) {
+	changed := false
+	failures := []string{}
+	for _, f := range append(append([]*termFile{}, files.blacklist...), files.whitelist...) {
+		fileChanged, err := f.reload()
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+		changed = changed || fileChanged
+	}
+	if len(failures) > 0 {
+		return changed, fmt.Errorf("%s", strings.Join(failures, "\n"))
+	}
+	return changed, nil
+}
+
+// apply adds the terms from every list to those given directly.
+func (files *termFiles) apply(opts Options) Options {
+	opts.Blacklist = append([]string{}, opts.Blacklist...)
+	for _, f := range files.blacklist {
+		opts.Blacklist = append(opts.Blacklist, f.terms...)
+	}
+	opts.Whitelist = append([]string{}, opts.Whitelist...)
+	for _, f := range files.whitelist {
+		opts.Whitelist = append(opts.Whitelist, f.terms...)
+	}
+	return opts
+}
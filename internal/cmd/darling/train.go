@@ -0,0 +1,46 @@
+package darling
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/snark/darling/pkg/filter"
+	"os"
+)
+
+// Train builds a classifier model from the items of the feeds named by
+// like and dislike, writing it to path or printing it.
+func Train(opts Options, like []string, dislike []string, path string) int {
+	if len(like) == 0 || len(dislike) == 0 {
+		fmt.Fprintf(os.Stderr, "train needs feeds of items you like (--like) and dislike (--dislike)\n")
+		return 2
+	}
+	model := filter.NewModel()
+	for _, f := range fetchAll(opts, like) {
+		for _, item := range f.Items {
+			model.Train(*item, true)
+		}
+	}
+	for _, f := range fetchAll(opts, dislike) {
+		for _, item := range f.Items {
+			model.Train(*item, false)
+		}
+	}
+	if model.Liked.Items == 0 || model.Disliked.Items == 0 {
+		fmt.Fprintf(os.Stderr, "Unable to train without both liked and disliked items\n")
+		return 1
+	}
+	if path != "" {
+		if err := model.Save(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to write %s: %s\n", path, err)
+			return 1
+		}
+		return 0
+	}
+	buf, err := json.Marshal(model)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	fmt.Println(string(buf))
+	return 0
+}
@@ -0,0 +1,193 @@
+package darling
+
+import (
+	"fmt"
+	"github.com/mmcdole/gofeed"
+	"github.com/snark/darling/pkg/feed"
+	"os"
+	"sync"
+	"time"
+)
+
+// watchedSource is a feed location being polled by Watch, along with the
+// most recent copy of it.
+type watchedSource struct {
+	token   string
+	parsed  []*gofeed.Feed
+	cache   feed.CacheInfo
+	modTime time.Time
+	next    time.Time
+}
+
+// poll fetches the source if it's due, reporting whether it changed.
+// Failures are reported and retried after the usual interval, keeping
+// whatever copy we already had.
+func (s *watchedSource) poll(opts Options, interval time.Duration, now time.Time) bool {
+	if now.Before(s.next) {
+		return false
+	}
+	s.next = now.Add(interval)
+	if validateUrl(s.token) {
+		f, info, err := feed.FetchIfChanged(s.token, s.cache)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to fetch %s: %s\n", s.token, err)
+			return false
+		}
+		s.cache = info
+		if f != nil {
+			f.FeedLink = s.token
+			s.parsed = []*gofeed.Feed{f}
+			prepareFeeds(opts, s.token, s.parsed)
+		}
+		var latest *gofeed.Feed
+		if len(s.parsed) > 0 {
+			latest = s.parsed[0]
+		}
+		s.next = feed.NextPoll(latest, info, interval, now)
+		return f != nil
+	}
+	stat, err := os.Stat(s.token)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read %s: %s\n", s.token, err)
+		return false
+	}
+	if stat.ModTime().Equal(s.modTime) {
+		return false
+	}
+	fs, err := fetchToken(opts, s.token)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return false
+	}
+	s.parsed = fs
+	s.modTime = stat.ModTime()
+	return true
+}
+
+// Watch polls each feed location on its own schedule until the process
+// is killed, rebuilding opts.OutputFile whenever any of them changes.
+// Sources are polled at most every interval, and less often if their
+// <ttl>, <skipHours>, <skipDays> or HTTP caching headers ask for it.
+// Blacklist and whitelist files are checked on every pass, and a change
+// to any of them rebuilds the outputs too.
+func Watch(opts Options, interval time.Duration, feedTokens []string) int {
+	if opts.OutputFile == "" && len(opts.Routes) == 0 {
+		fmt.Fprintf(os.Stderr, "watch requires an output file (-o) or routes\n")
+		return 2
+	}
+	sources, files, err := newSources(&opts, feedTokens)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 2
+	}
+
+	for {
+		now := time.Now()
+		anyChanged, parsed, next := pollAll(opts, interval, sources, now)
+		filesChanged, err := files.reload()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+		}
+		if anyChanged || filesChanged {
+			if err := rebuild(files.apply(opts), parsed); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+			}
+		}
+		time.Sleep(time.Until(next))
+	}
+}
+
+// newSources checks the options for a long-running process, loading the
+// classifier, and sets up the sources and term lists it will keep
+// polling.
+func newSources(opts *Options, feedTokens []string) ([]*watchedSource, *termFiles, error) {
+	files := newTermFiles(*opts)
+	if _, err := files.reload(); err != nil {
+		return nil, nil, err
+	}
+	if err := loadModel(opts); err != nil {
+		return nil, nil, err
+	}
+	if err := loadScoring(opts); err != nil {
+		return nil, nil, err
+	}
+	if err := loadRedirects(opts); err != nil {
+		return nil, nil, err
+	}
+	if _, err := newFilters(files.apply(*opts), time.Now()); err != nil {
+		return nil, nil, err
+	}
+	if _, err := feed.NewUndated(opts.Undated, &feed.SeenStore{}); err != nil {
+		return nil, nil, err
+	}
+	if err := sanitize(*opts, nil); err != nil {
+		return nil, nil, err
+	}
+	if err := checkMetadata(*opts); err != nil {
+		return nil, nil, err
+	}
+	sources := []*watchedSource{}
+	for _, token := range expandTokens(feedTokens) {
+		if validateUrl(token) || validatePath(token) {
+			sources = append(sources, &watchedSource{token: token})
+		} else {
+			fmt.Fprintf(os.Stderr, "Unable to process %s as a feed location\n", token)
+		}
+	}
+	if len(sources) == 0 {
+		return nil, nil, fmt.Errorf("Nothing to watch")
+	}
+	return sources, files, nil
+}
+
+// pollAll polls every source which is due, reporting whether any of
+// them changed, the latest copy of every feed, and when the next poll is
+// due.
+func pollAll(opts Options, interval time.Duration, sources []*watchedSource, now time.Time) (bool, []*gofeed.Feed, time.Time) {
+	changed := make([]bool, len(sources))
+	var wg sync.WaitGroup
+	for i, s := range sources {
+		wg.Add(1)
+		go func(i int, s *watchedSource) {
+			defer wg.Done()
+			changed[i] = s.poll(opts, interval, now)
+		}(i, s)
+	}
+	wg.Wait()
+	saveRedirects(opts)
+
+	anyChanged := false
+	parsed := []*gofeed.Feed{}
+	next := now.Add(interval)
+	for i, s := range sources {
+		anyChanged = anyChanged || changed[i]
+		parsed = append(parsed, s.parsed...)
+		if s.next.Before(next) {
+			next = s.next
+		}
+	}
+	return anyChanged, parsed, next
+}
+
+// rebuild writes every output Watch is responsible for.
+func rebuild(opts Options, parsed []*gofeed.Feed) error {
+	if opts.scoring != nil {
+		opts.scoring.Forget()
+	}
+	if len(opts.Routes) > 0 {
+		if _, err := writeRoutes(opts, parsed); err != nil {
+			return err
+		}
+	}
+	if opts.OutputFile == "" {
+		return nil
+	}
+	outfeed, err := buildFeed(opts, parsed, nil)
+	if err == nil {
+		err = writeOutputFile(opts, outfeed)
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to write %s: %s", opts.OutputFile, err)
+	}
+	return nil
+}
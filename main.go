@@ -3,9 +3,12 @@ package main
 import (
 	"fmt"
 	"github.com/snark/darling/internal/cmd/darling"
+	"github.com/snark/darling/pkg/filter"
+	"github.com/snark/darling/pkg/output"
 	flag "github.com/spf13/pflag"
 	"os"
 	"strings"
+	"time"
 )
 
 type arrayFlags []string
@@ -26,25 +29,198 @@ func (i *arrayFlags) Type() string {
 func main() {
 	var blacklistWords arrayFlags
 	var whitelistWords arrayFlags
+	var blacklistFiles arrayFlags
+	var whitelistFiles arrayFlags
+	var routeSpecs arrayFlags
+	var scores arrayFlags
+	var fullText arrayFlags
+	var scoreFields arrayFlags
 	flag.VarP(&blacklistWords, "blacklist", "b", "blacklist term")
 	flag.VarP(&whitelistWords, "whitelist", "w", "whitelist term")
+	flag.Var(&blacklistFiles, "blacklist-file", "file or URL of blacklist terms, one per line")
+	flag.Var(&whitelistFiles, "whitelist-file", "file or URL of whitelist terms, one per line")
+	flag.Var(&scores, "score", "weighted term for scoring items (TERM:N, e.g. crypto:-2)")
+	flag.Var(&scoreFields, "score-field", "multiply the weight of terms found in a field (FIELD=N, e.g. title=3)")
+	var minScore = flag.Float64("min-score", 0, "restrict to items scoring at least n, with --score")
+	var classifier = flag.String("classifier", "", "restrict to items a model made by 'darling train' thinks you'll like")
+	var minProb = flag.Float64("min-prob", 0.5, "how sure the classifier must be that you'll like an item")
+	var like arrayFlags
+	var dislike arrayFlags
+	flag.Var(&like, "like", "feed of items you like, for 'darling train'")
+	flag.Var(&dislike, "dislike", "feed of items you dislike, for 'darling train'")
+	var explain = flag.Bool("explain", false, "describe how each item was scored on stderr")
+	flag.Var(&fullText, "fulltext", "read a feed, replacing its items' content with the articles they link to")
+	var stripParams arrayFlags
+	var cleanLinks = flag.Bool("clean-links", false, "remove tracking parameters from links, and replace AMP links with the pages they stand in for")
+	flag.Var(&stripParams, "strip-param", "remove a query parameter from links, as --clean-links does (a trailing * matches any suffix)")
+	var resolveRedirects = flag.Bool("resolve-redirects", false, "replace links with where they redirect to, as --clean-links does")
+	var tagSpecs arrayFlags
+	flag.Var(&tagSpecs, "tag", "add a category to items matching terms (CATEGORY=TERM,TERM,... or CATEGORY@FIELD=TERM,...)")
+	var rewriteSpecs arrayFlags
+	flag.Var(&rewriteSpecs, "rewrite", "rewrite a field of every item before filtering (FIELD=s/PATTERN/REPLACEMENT/)")
+	var minWords = flag.Int("min-words", 0, "restrict to items with at least n words of text")
+	var maxWords = flag.Int("max-words", 0, "restrict to items with at most n words of text")
+	var minReadingTime = flag.Duration("min-reading-time", 0, "restrict to items taking at least this long to read")
+	var maxReadingTime = flag.Duration("max-reading-time", 0, "restrict to items taking at most this long to read")
+	var readingTime = flag.Bool("reading-time", false, "add each item's estimated reading time to the output")
+	var summarySpec = flag.String("summary", "", "replace item descriptions with plain text summaries of n characters, or n sentences as in '2s'")
+	var dropContent = flag.Bool("drop-content", false, "remove the full content of items")
+	var sanitize = flag.String("sanitize", "", "strip unsafe HTML from items ('html') or all of it ('text')")
+	flag.Var(&routeSpecs, "route", "send items matching terms to a file of their own (FILE=TERM,TERM,...; a bare FILE takes the rest)")
 	var number = flag.IntP("limit", "n", 0, "restrict to n matching items per feed")
 	var after = flag.String("since", "", "restrict to items after a given time")
-	var outputType = flag.String("output", "", "output type ('rss' or 'atom'; rss is default)")
+	var outputType = flag.String("output", "", "output type ('rss', 'atom' or 'ndjson'; rss is default)")
+	var inputType = flag.String("input", "", "how to read stdin and paths ('feed' or 'ndjson'; feed is default)")
+	var exitEmpty = flag.Int("exit-empty", 0, "exit with status n if no items match")
+	var outputFile = flag.StringP("output-file", "o", "", "write the feed to a file, replacing it atomically")
+	var quiet = flag.Bool("quiet-if-unchanged", false, "print nothing if the result matches the previous run")
+	var prefixSource = flag.Bool("prefix-source", false, "prefix item titles with the title of their feed")
+	var undated = flag.String("undated", "now", "what to do with undated items ('now', 'drop', 'first-seen' or 'feed-updated')")
+	var configPath = flag.String("config", "", "read options from a JSON config file")
+	var metadata output.Metadata
+	flag.StringVar(&metadata.Title, "feed-title", "", "title of the output feed")
+	flag.StringVar(&metadata.Link, "feed-link", "", "link of the output feed")
+	flag.StringVar(&metadata.Description, "feed-description", "", "description of the output feed")
+	flag.StringVar(&metadata.Author, "feed-author", "", "author of the output feed ('Name' or 'Name <email>')")
+	flag.StringVar(&metadata.Language, "feed-language", "", "language of the output feed")
+	flag.StringVar(&metadata.Image, "feed-image", "", "image (logo) URL of the output feed")
+	flag.StringVar(&metadata.Icon, "feed-icon", "", "icon URL of the output feed (atom only)")
+	flag.StringVar(&metadata.Copyright, "feed-copyright", "", "copyright notice of the output feed")
+	flag.StringVar(&metadata.Self, "feed-self", "", "URL the output feed will be published at")
+	var addr = flag.String("addr", ":8080", "address for 'darling serve' to listen on")
+	var baseURL = flag.String("base-url", "", "URL 'darling serve' is reached at, for --feedback links")
+	var feedback = flag.Bool("feedback", false, "add 👍/👎 feedback links to items served by 'darling serve'")
+	var interval = flag.Duration("interval", time.Hour, "minimum time between polls of each feed in watch mode")
 	flag.Usage = func() {
-		fmt.Printf("Usage: darling [options] <feed url or path>...\n")
+		fmt.Printf("Usage: darling [options] <feed url, path or - for stdin>...\n")
+		fmt.Printf("       darling watch [-o <file>] [--route <route>]... [options] <feed url or path>...\n")
+		fmt.Printf("       darling serve [--addr <addr>] [--base-url <url> --feedback] [--route <route>]... [options] <feed url or path>...\n")
+		fmt.Printf("       darling train --like <feed>... --dislike <feed>... [-o <model.json>]\n")
 		flag.PrintDefaults()
 	}
 	flag.Parse()
 	tail := flag.Args()
+	command := ""
+	if len(tail) > 0 && (tail[0] == "watch" || tail[0] == "serve" || tail[0] == "train") {
+		command, tail = tail[0], tail[1:]
+	}
 	stdinStat, err := os.Stdin.Stat()
 	if err != nil {
 		panic(err)
 	}
+	// With nothing else to read, read stdin if it's a file with something
+	// in it. Pipes need an explicit -, since under cron or CI stdin can be
+	// an empty pipe that would otherwise be waited on.
 	hasPipe := stdinStat.Mode()&os.ModeCharDevice == 0 && stdinStat.Size() > 0
 
-	if (hasPipe || len(tail) > 0) && *number >= 0 {
-		darling.FilterFeeds(blacklistWords, whitelistWords, after, number, outputType, tail)
+	routes := []darling.Route{}
+	for _, spec := range routeSpecs {
+		route, err := darling.ParseRoute(spec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(2)
+		}
+		routes = append(routes, route)
+	}
+
+	rewrites := []darling.Rewrite{}
+	for _, spec := range rewriteSpecs {
+		rule, err := darling.ParseRewrite(spec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(2)
+		}
+		rewrites = append(rewrites, rule)
+	}
+
+	tags := []*filter.Tag{}
+	for _, spec := range tagSpecs {
+		tag, err := darling.ParseTag(spec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(2)
+		}
+		tags = append(tags, tag)
+	}
+
+	var summary output.Summary
+	if *summarySpec != "" {
+		summary, err = output.ParseSummary(*summarySpec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(2)
+		}
+	}
+
+	opts := darling.Options{
+		Blacklist:        blacklistWords,
+		Whitelist:        whitelistWords,
+		BlacklistFiles:   blacklistFiles,
+		WhitelistFiles:   whitelistFiles,
+		Since:            *after,
+		Limit:            *number,
+		OutputType:       *outputType,
+		InputType:        *inputType,
+		ExitEmpty:        *exitEmpty,
+		QuietIfUnchanged: *quiet,
+		OutputFile:       *outputFile,
+		Routes:           routes,
+		Metadata:         metadata,
+		PrefixSource:     *prefixSource,
+		Undated:          *undated,
+		Scores:           scores,
+		ScoreFields:      scoreFields,
+		MinScore:         *minScore,
+		Explain:          *explain,
+		Classifier:       *classifier,
+		MinProb:          *minProb,
+		FullText:         fullText,
+		Sanitize:         *sanitize,
+		CleanLinks:       *cleanLinks,
+		StripParams:      stripParams,
+		ResolveRedirects: *resolveRedirects,
+		Rewrites:         rewrites,
+		Tags:             tags,
+		Summary:          summary,
+		DropContent:      *dropContent,
+		MinWords:         *minWords,
+		MaxWords:         *maxWords,
+		MinReadingTime:   *minReadingTime,
+		MaxReadingTime:   *maxReadingTime,
+		ReadingTime:      *readingTime,
+	}
+	if *configPath != "" {
+		config, err := darling.LoadConfig(*configPath)
+		if err == nil {
+			err = config.Apply(&opts, flag.CommandLine.Changed)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to load config %s: %s\n", *configPath, err)
+			os.Exit(2)
+		}
+		tail = append(config.Feeds, tail...)
+	}
+	// Feeds given to --fulltext are read like any other
+	for _, location := range opts.FullText {
+		listed := false
+		for _, token := range tail {
+			listed = listed || token == location
+		}
+		if !listed {
+			tail = append(tail, location)
+		}
+	}
+	if command == "train" {
+		// Only an -o given here is meant for the model
+		os.Exit(darling.Train(opts, like, dislike, *outputFile))
+	} else if command == "serve" && len(tail) > 0 && opts.Limit >= 0 {
+		os.Exit(darling.Serve(opts, *interval, *addr, *baseURL, *feedback, tail))
+	} else if command == "watch" && len(tail) > 0 && opts.Limit >= 0 {
+		os.Exit(darling.Watch(opts, *interval, tail))
+	} else if command == "" && len(tail) > 0 && opts.Limit >= 0 {
+		os.Exit(darling.FilterFeeds(opts, tail))
+	} else if command == "" && hasPipe && opts.Limit >= 0 {
+		os.Exit(darling.FilterFeeds(opts, []string{"-"}))
 	} else {
 		flag.Usage()
 	}
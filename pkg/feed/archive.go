@@ -0,0 +1,112 @@
+package feed
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"io/ioutil"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	bzip2Magic = []byte("BZh")
+	zipMagic   = []byte("PK\x03\x04")
+)
+
+// Decompress undoes gzip, zstd or bzip2 compression, recognised by its
+// magic number. Anything else is returned as it is.
+func Decompress(data []byte) ([]byte, error) {
+	var r io.Reader
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	case bytes.HasPrefix(data, zstdMagic):
+		zr, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case bytes.HasPrefix(data, bzip2Magic):
+		r = bzip2.NewReader(bytes.NewReader(data))
+	default:
+		return data, nil
+	}
+	return ioutil.ReadAll(r)
+}
+
+// isTar checks for the "ustar" magic of POSIX and GNU tar headers.
+func isTar(data []byte) bool {
+	return len(data) > 262 && bytes.Equal(data[257:262], []byte("ustar"))
+}
+
+// Unpack turns the contents of a file into the documents it holds. That's
+// the file itself, decompressed if need be, or each of the regular files
+// in a tar or zip archive, which may themselves be compressed.
+func Unpack(data []byte) ([][]byte, error) {
+	data, err := Decompress(data)
+	if err != nil {
+		return nil, err
+	}
+	docs := [][]byte{}
+	switch {
+	case isTar(data):
+		tr := tar.NewReader(bytes.NewReader(data))
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if header.Typeflag != tar.TypeReg {
+				continue
+			}
+			doc, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			if doc, err = Decompress(doc); err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+		}
+	case bytes.HasPrefix(data, zipMagic):
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range zr.File {
+			if !file.Mode().IsRegular() {
+				continue
+			}
+			rc, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			doc, err := ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+			if doc, err = Decompress(doc); err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+		}
+	default:
+		docs = append(docs, data)
+	}
+	return docs, nil
+}
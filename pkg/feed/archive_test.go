@@ -0,0 +1,92 @@
+package feed_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/snark/darling/pkg/feed"
+	"io/ioutil"
+	"testing"
+)
+
+func gzipped(data []byte) []byte {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	w.Write(data)
+	w.Close()
+	return b.Bytes()
+}
+
+func TestUnpackCompressed(t *testing.T) {
+	lobsters, _ := ioutil.ReadFile("../../testdata/lobste.rs.rss")
+	var zstded bytes.Buffer
+	zw, _ := zstd.NewWriter(&zstded)
+	zw.Write(lobsters)
+	zw.Close()
+	var tests = []struct {
+		name string
+		data []byte
+	}{
+		{"plain", lobsters},
+		{"gzip", gzipped(lobsters)},
+		{"zstd", zstded.Bytes()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := feed.Unpack(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(docs) != 1 || !bytes.Equal(docs[0], lobsters) {
+				t.Errorf("got %d documents, not the original feed", len(docs))
+			}
+		})
+	}
+}
+
+func TestUnpackArchives(t *testing.T) {
+	lobsters, _ := ioutil.ReadFile("../../testdata/lobste.rs.rss")
+	waxy, _ := ioutil.ReadFile("../../testdata/waxy.org.rss")
+	files := map[string][]byte{"lobste.rs.rss": lobsters, "waxy.org.rss.gz": gzipped(waxy)}
+
+	var tarred bytes.Buffer
+	tw := tar.NewWriter(&tarred)
+	tw.WriteHeader(&tar.Header{Name: "feeds/", Typeflag: tar.TypeDir, Mode: 0755})
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	for name, data := range files {
+		tw.WriteHeader(&tar.Header{Name: "feeds/" + name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data))})
+		tw.Write(data)
+		w, _ := zw.Create(name)
+		w.Write(data)
+	}
+	tw.Close()
+	zw.Close()
+
+	var tests = []struct {
+		name string
+		data []byte
+	}{
+		{"tar", tarred.Bytes()},
+		{"tar.gz", gzipped(tarred.Bytes())},
+		{"zip", zipped.Bytes()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := feed.Unpack(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(docs) != 2 {
+				t.Fatalf("got %d documents from an archive of two", len(docs))
+			}
+			for _, doc := range docs {
+				if !bytes.Equal(doc, lobsters) && !bytes.Equal(doc, waxy) {
+					t.Errorf("got a document which isn't one of the archived feeds")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/net/html"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// xmlBases finds the xml:base in effect for each item or entry in a feed
+// document, in order. Items without one have an empty base.
+func xmlBases(doc []byte) []string {
+	decoder := xml.NewDecoder(bytes.NewReader(doc))
+	decoder.Strict = false
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	bases := []string{}
+	stack := []string{""}
+	// How deep within an item we are, so that elements in its content
+	// aren't taken for items
+	inItem := 0
+	for {
+		token, err := decoder.RawToken()
+		if err != nil {
+			return bases
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			base := stack[len(stack)-1]
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xml" && attr.Name.Local == "base" {
+					base = resolveAgainst(base, attr.Value)
+				}
+			}
+			stack = append(stack, base)
+			if inItem > 0 {
+				inItem++
+			} else if t.Name.Local == "entry" || t.Name.Local == "item" {
+				inItem = 1
+				bases = append(bases, base)
+			}
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+			if inItem > 0 {
+				inItem--
+			}
+		}
+	}
+}
+
+// resolveAgainst resolves ref against base, unless either is unusable.
+func resolveAgainst(base, ref string) string {
+	ref = strings.TrimSpace(ref)
+	u, err := url.Parse(ref)
+	if err != nil {
+		return base
+	}
+	b, err := url.Parse(base)
+	if base == "" || err != nil {
+		return ref
+	}
+	return b.ResolveReference(u).String()
+}
+
+// itemBase is the URL the relative links in an item are relative to: its
+// xml:base, resolved against the feed's link and the URL it was fetched
+// from, in that order. It's nil if none of those gives an absolute URL.
+func itemBase(parsed *gofeed.Feed, item *gofeed.Item) *url.URL {
+	base := ""
+	for _, ref := range []string{parsed.FeedLink, parsed.Link, item.Custom["xml:base"]} {
+		if ref != "" {
+			base = resolveAgainst(base, ref)
+		}
+	}
+	u, err := url.Parse(base)
+	if err != nil || !u.IsAbs() {
+		return nil
+	}
+	return u
+}
+
+// absolute resolves link against base, leaving it alone if it can't.
+func absolute(base *url.URL, link string) string {
+	if base == nil || link == "" {
+		return link
+	}
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.IsAbs() {
+		return link
+	}
+	return base.ResolveReference(u).String()
+}
+
+// absoluteContent resolves the src and href attributes in an HTML
+// fragment against base.
+func absoluteContent(base *url.URL, fragment string) string {
+	if base == nil {
+		return fragment
+	}
+	return rewriteAttrs(fragment, func(tag, key string) bool {
+		return key == "src" || key == "href"
+	}, func(link string) string {
+		return absolute(base, link)
+	})
+}
+
+// rewriteAttrs rewrites the attributes of an HTML fragment for which
+// wanted is true, leaving everything else exactly as it was.
+func rewriteAttrs(fragment string, wanted func(tag, key string) bool, rewrite func(string) string) string {
+	if !strings.Contains(fragment, "<") {
+		return fragment
+	}
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return b.String()
+		}
+		raw := z.Raw()
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			b.Write(raw)
+			continue
+		}
+		// Raw is only valid until the tokenizer moves on
+		raw = append([]byte{}, raw...)
+		token := z.Token()
+		changed := false
+		for i, attr := range token.Attr {
+			if wanted(token.Data, attr.Key) {
+				if val := rewrite(attr.Val); val != attr.Val {
+					token.Attr[i].Val = val
+					changed = true
+				}
+			}
+		}
+		if changed {
+			b.WriteString(token.String())
+		} else {
+			b.Write(raw)
+		}
+	}
+}
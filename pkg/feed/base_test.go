@@ -0,0 +1,70 @@
+package feed_test
+
+import (
+	"github.com/snark/darling/pkg/feed"
+	"strings"
+	"testing"
+)
+
+const relativeAtom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xml:base="https://example.com/blog/">
+  <title>Relative</title>
+  <link href="https://example.com/"/>
+  <entry>
+    <title>Inherited</title>
+    <id>1</id>
+    <updated>2019-08-01T00:00:00Z</updated>
+    <link href="posts/one"/>
+    <link rel="enclosure" href="audio/one.mp3" type="audio/mpeg" length="1234"/>
+    <content type="html">&lt;p&gt;&lt;a href="../about"&gt;About&lt;/a&gt; &lt;img src="/img/one.png"&gt; &lt;a href="https://elsewhere.com/"&gt;Away&lt;/a&gt;&lt;/p&gt;</content>
+  </entry>
+  <entry xml:base="https://static.example.net/2019/">
+    <title>Own base</title>
+    <id>2</id>
+    <updated>2019-08-02T00:00:00Z</updated>
+    <link href="two.html"/>
+  </entry>
+</feed>`
+
+const relativeRSS = `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Relative</title><link>https://example.org/news/</link>
+<item><title>One</title><link>/stories/1</link><guid>1</guid><description>&lt;img src="pic.jpg"&gt;</description></item>
+</channel></rss>`
+
+func TestProcessItemsResolvesLinks(t *testing.T) {
+	parsed, err := feed.ParseDocuments([]byte(relativeAtom))
+	if err != nil {
+		t.Fatal(err)
+	}
+	undated, _ := feed.NewUndated("now", nil)
+	items := feed.ProcessItems(parsed[0], nil, undated)
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+	if want := "https://example.com/blog/posts/one"; items[0].Link.Href != want {
+		t.Errorf("got link %q, want %q", items[0].Link.Href, want)
+	}
+	if items[0].Enclosure == nil || items[0].Enclosure.Url != "https://example.com/blog/audio/one.mp3" {
+		t.Errorf("got enclosure %+v, want an absolute URL", items[0].Enclosure)
+	}
+	for _, want := range []string{`href="https://example.com/about"`, `src="https://example.com/img/one.png"`, `href="https://elsewhere.com/"`} {
+		if !strings.Contains(items[0].Content, want) {
+			t.Errorf("content %q is missing %s", items[0].Content, want)
+		}
+	}
+	if want := "https://static.example.net/2019/two.html"; items[1].Link.Href != want {
+		t.Errorf("got link %q, want %q", items[1].Link.Href, want)
+	}
+
+	parsed, err = feed.ParseDocuments([]byte(relativeRSS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	items = feed.ProcessItems(parsed[0], nil, undated)
+	if want := "https://example.org/stories/1"; items[0].Link.Href != want {
+		t.Errorf("got link %q, want %q", items[0].Link.Href, want)
+	}
+	if want := `<img src="https://example.org/news/pic.jpg">`; items[0].Description != want {
+		t.Errorf("got description %q, want %q", items[0].Description, want)
+	}
+}
@@ -0,0 +1,65 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/mmcdole/gofeed"
+	"io"
+)
+
+// SplitDocuments splits a stream of concatenated XML documents, such as
+// several feeds piped in one after another, into its documents. Anything
+// it can't make sense of is left in the last document for the feed
+// parser to complain about.
+func SplitDocuments(data []byte) [][]byte {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// We're only looking for where each root element ends, so be
+	// forgiving about entities and encodings
+	decoder.Strict = false
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	docs := [][]byte{}
+	start := int64(0)
+	depth := 0
+	for {
+		token, err := decoder.RawToken()
+		if err != nil {
+			break
+		}
+		switch token.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+			if depth == 0 {
+				end := decoder.InputOffset()
+				docs = append(docs, data[start:end])
+				start = end
+			}
+		}
+	}
+	if len(bytes.TrimSpace(data[start:])) > 0 {
+		docs = append(docs, data[start:])
+	}
+	return docs
+}
+
+// ParseDocuments parses every feed in a stream of concatenated feed
+// documents.
+func ParseDocuments(data []byte) ([]*gofeed.Feed, error) {
+	parsed := []*gofeed.Feed{}
+	docs := SplitDocuments(data)
+	for i, doc := range docs {
+		f, err := parse(doc)
+		if err != nil {
+			if len(docs) == 1 {
+				return nil, err
+			}
+			return nil, fmt.Errorf("document %d: %s", i+1, err)
+		}
+		parsed = append(parsed, f)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,42 @@
+package feed_test
+
+import (
+	"github.com/snark/darling/pkg/feed"
+	"io/ioutil"
+	"testing"
+)
+
+func TestParseDocuments(t *testing.T) {
+	lobsters, _ := ioutil.ReadFile("../../testdata/lobste.rs.rss")
+	waxy, _ := ioutil.ReadFile("../../testdata/waxy.org.rss")
+	stream := append(append(append([]byte{}, lobsters...), '\n'), waxy...)
+	parsed, err := feed.ParseDocuments(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != 2 {
+		t.Fatalf("got %d feeds from two concatenated documents", len(parsed))
+	}
+	if parsed[0].Title != "Lobsters" || parsed[1].Title != "Waxy.org" {
+		t.Errorf("got feeds titled %s and %s", parsed[0].Title, parsed[1].Title)
+	}
+}
+
+func TestParseDocumentsSingle(t *testing.T) {
+	lobsters, _ := ioutil.ReadFile("../../testdata/lobste.rs.rss")
+	parsed, err := feed.ParseDocuments(lobsters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != 1 || len(parsed[0].Items) != 25 {
+		t.Errorf("got %d feeds from one document", len(parsed))
+	}
+}
+
+func TestParseDocumentsGarbage(t *testing.T) {
+	lobsters, _ := ioutil.ReadFile("../../testdata/lobste.rs.rss")
+	stream := append(append([]byte{}, lobsters...), []byte("<html><body>Not a feed</body></html>")...)
+	if _, err := feed.ParseDocuments(stream); err == nil {
+		t.Errorf("did not return an error for a stream ending in a non-feed")
+	}
+}
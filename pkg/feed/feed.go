@@ -1,28 +1,151 @@
 package feed
 
 import (
+	"bytes"
+	"fmt"
 	"github.com/gorilla/feeds"
 	"github.com/mmcdole/gofeed"
+	"github.com/mmcdole/gofeed/atom"
+	"github.com/mmcdole/gofeed/rss"
 	"github.com/snark/darling/pkg/filter"
+	"github.com/snark/darling/pkg/output"
+	"strings"
 	"time"
 )
 
-func Fetch(url string) (*gofeed.Feed, error) {
-	// TODO: Caching support
+// rssTranslator keeps the RSS scheduling hints (<ttl>, <skipHours> and
+// <skipDays>) which gofeed's universal feed would otherwise drop. They're
+// stashed in Feed.Custom under their element names, with lists joined by
+// commas.
+type rssTranslator struct {
+	gofeed.DefaultRSSTranslator
+}
+
+func (t *rssTranslator) Translate(feed interface{}) (*gofeed.Feed, error) {
+	parsed, err := t.DefaultRSSTranslator.Translate(feed)
+	if err != nil {
+		return parsed, err
+	}
+	rf, found := feed.(*rss.Feed)
+	if !found {
+		return nil, fmt.Errorf("Feed did not match expected type of *rss.Feed")
+	}
+	custom := map[string]string{}
+	if rf.TTL != "" {
+		custom["ttl"] = strings.TrimSpace(rf.TTL)
+	}
+	if len(rf.SkipHours) > 0 {
+		custom["skipHours"] = strings.Join(rf.SkipHours, ",")
+	}
+	if len(rf.SkipDays) > 0 {
+		custom["skipDays"] = strings.Join(rf.SkipDays, ",")
+	}
+	addCustom(parsed, custom)
+	return parsed, nil
+}
+
+// atomTranslator keeps the feed's <id>, for attributing its entries once
+// they're mixed in with others. It's stashed in Feed.Custom as "id".
+type atomTranslator struct {
+	gofeed.DefaultAtomTranslator
+}
+
+func (t *atomTranslator) Translate(feed interface{}) (*gofeed.Feed, error) {
+	parsed, err := t.DefaultAtomTranslator.Translate(feed)
+	if err != nil {
+		return parsed, err
+	}
+	af, found := feed.(*atom.Feed)
+	if !found {
+		return nil, fmt.Errorf("Feed did not match expected type of *atom.Feed")
+	}
+	if af.ID != "" {
+		addCustom(parsed, map[string]string{"id": strings.TrimSpace(af.ID)})
+	}
+	return parsed, nil
+}
+
+// addCustom adds custom to whatever the default translator put in the
+// feed's Custom map.
+func addCustom(parsed *gofeed.Feed, custom map[string]string) {
+	if len(custom) == 0 {
+		return
+	}
+	if parsed.Custom == nil {
+		parsed.Custom = map[string]string{}
+	}
+	for key, value := range custom {
+		parsed.Custom[key] = value
+	}
+}
+
+func newParser() *gofeed.Parser {
 	fp := gofeed.NewParser()
-	parsed, err := fp.ParseURL(url)
-	return parsed, err
+	fp.RSSTranslator = &rssTranslator{}
+	fp.AtomTranslator = &atomTranslator{}
+	return fp
+}
+
+// parse parses a single feed document. gofeed doesn't know about
+// xml:base, so the base in effect for each item is noted in its Custom
+// map as "xml:base".
+func parse(doc []byte) (*gofeed.Feed, error) {
+	parsed, err := newParser().Parse(bytes.NewReader(doc))
+	if err != nil {
+		return nil, err
+	}
+	bases := xmlBases(doc)
+	if len(bases) != len(parsed.Items) {
+		return parsed, nil
+	}
+	for i, base := range bases {
+		if base == "" {
+			continue
+		}
+		item := parsed.Items[i]
+		if item.Custom == nil {
+			item.Custom = map[string]string{}
+		}
+		item.Custom["xml:base"] = base
+	}
+	return parsed, nil
+}
+
+func Fetch(url string) (*gofeed.Feed, error) {
+	f, _, err := FetchIfChanged(url, CacheInfo{})
+	return f, err
 }
 
 func ParseFromString(s string) (*gofeed.Feed, error) {
-	fp := gofeed.NewParser()
-	parsed, err := fp.ParseString(s)
-	return parsed, err
+	return parse([]byte(s))
 }
 
-func ProcessItems(parsedItems []*gofeed.Item, filters []filter.ItemFilter) []*feeds.Item {
-	outitems := []*feeds.Item{}
-	for _, item := range parsedItems {
+// ProcessItems converts the items of a parsed feed which pass every
+// filter into output items. Items without a date of their own are dated
+// (or dropped) by undated.
+func ProcessItems(parsed *gofeed.Feed, filters []filter.ItemFilter, undated Undated) []*output.Item {
+	source := &output.Source{
+		URL:   parsed.FeedLink,
+		Title: parsed.Title,
+		Link:  parsed.Link,
+		ID:    parsed.Custom["id"],
+	}
+	outitems := []*output.Item{}
+	for _, item := range parsed.Items {
+		id := ItemID(item)
+		if item.PublishedParsed == nil && item.UpdatedParsed == nil {
+			created, ok := undated(parsed, id)
+			if !ok {
+				continue
+			}
+			// Filters see the date we've given it; the parsed item is
+			// left alone, since it may be processed again.
+			if !created.IsZero() {
+				dated := *item
+				dated.PublishedParsed = &created
+				item = &dated
+			}
+		}
 		missed := false
 		for i := range filters {
 			if !filters[i].Match(*item) {
@@ -33,27 +156,66 @@ func ProcessItems(parsedItems []*gofeed.Item, filters []filter.ItemFilter) []*fe
 		if !missed {
 			// TODO: Currently unhandled:
 			// * Author
-			// * Enclosures
-			// * Categories
 			// * Extensions
+			// Links are made absolute, since the item may end up in a
+			// feed somewhere else entirely
+			base := itemBase(parsed, item)
 			newitem := &feeds.Item{
 				//Author: item.Author,
-				Content:     item.Content,
-				Description: item.Description,
-				Id:          item.GUID,
-				Link:        &feeds.Link{Href: item.Link},
+				Content:     absoluteContent(base, item.Content),
+				Description: absoluteContent(base, item.Description),
+				Id:          id,
+				Link:        &feeds.Link{Href: absolute(base, item.Link)},
 				Title:       item.Title,
 			}
+			if len(item.Enclosures) > 0 {
+				enclosure := item.Enclosures[0]
+				newitem.Enclosure = &feeds.Enclosure{
+					Url:    absolute(base, enclosure.URL),
+					Length: enclosure.Length,
+					Type:   enclosure.Type,
+				}
+			}
 			if item.PublishedParsed != nil {
 				newitem.Created = *item.PublishedParsed
+			} else if item.UpdatedParsed != nil {
+				newitem.Created = *item.UpdatedParsed
 			} else {
 				newitem.Created = time.Now()
 			}
 			if item.UpdatedParsed != nil {
 				newitem.Updated = *item.UpdatedParsed
 			}
-			outitems = append(outitems, newitem)
+			outitem := &output.Item{Item: newitem, Source: source}
+			outitem.DatedNow = item.PublishedParsed == nil && item.UpdatedParsed == nil
+			outitem.Categories = append([]string{}, item.Categories...)
+			for i := range filters {
+				if scorer, ok := filters[i].(filter.Scorer); ok {
+					score := scorer.Score(*item)
+					outitem.Score = &score
+				}
+				if timer, ok := filters[i].(filter.ReadingTimer); ok {
+					outitem.ReadingTime = timer.ReadingTime(*item)
+				}
+				if tagger, ok := filters[i].(filter.Tagger); ok {
+					for _, tag := range tagger.Tags(*item) {
+						if !hasCategory(outitem.Categories, tag) {
+							outitem.Categories = append(outitem.Categories, tag)
+						}
+					}
+				}
+			}
+			outitems = append(outitems, outitem)
 		}
 	}
 	return outitems
 }
+
+func hasCategory(categories []string, category string) bool {
+	for _, c := range categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
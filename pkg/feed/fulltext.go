@@ -0,0 +1,221 @@
+package feed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/net/html"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How many articles are fetched at once for a single feed
+const fullTextFetches = 4
+
+// Articles with less text than this are probably not articles at all
+const minArticleLength = 250
+
+// How long before an article that couldn't be fetched or found is tried
+// again
+const articleRetry = 24 * time.Hour
+
+// ArticleCache keeps the articles extracted by FullText, so that each is
+// only fetched once.
+type ArticleCache struct {
+	dir string
+}
+
+// NewArticleCache keeps articles in dir, which needn't exist yet.
+func NewArticleCache(dir string) *ArticleCache {
+	return &ArticleCache{dir: dir}
+}
+
+func (cache *ArticleCache) path(link string, ext string) string {
+	sum := sha256.Sum256([]byte(link))
+	return filepath.Join(cache.dir, hex.EncodeToString(sum[:16])+ext)
+}
+
+// Article returns the main content of the page at link, from the cache
+// if it's there. Failures are remembered too, for articleRetry.
+func (cache *ArticleCache) Article(link string) (string, error) {
+	if buf, err := ioutil.ReadFile(cache.path(link, ".html")); err == nil {
+		return string(buf), nil
+	}
+	failed := cache.path(link, ".failed")
+	if info, err := os.Stat(failed); err == nil && time.Since(info.ModTime()) < articleRetry {
+		if buf, err := ioutil.ReadFile(failed); err == nil {
+			return "", errors.New(string(buf))
+		}
+	}
+	if err := os.MkdirAll(cache.dir, 0755); err != nil {
+		return "", err
+	}
+	article, err := fetchArticle(link)
+	if err != nil {
+		// The fetch's error matters more than any from remembering it
+		ioutil.WriteFile(failed, []byte(err.Error()), 0644)
+		return "", err
+	}
+	os.Remove(failed)
+	return article, ioutil.WriteFile(cache.path(link, ".html"), []byte(article), 0644)
+}
+
+// fetchArticle fetches the page at link and extracts its article, with
+// its links made absolute against where the page was found.
+func fetchArticle(link string) (string, error) {
+	req, err := newRequest("GET", link)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", gofeed.HTTPError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	article, err := ExtractArticle(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return absoluteContent(resp.Request.URL, article), nil
+}
+
+// FullText replaces the content of each item with the article it links
+// to. Items whose articles can't be fetched or found are reported and
+// left alone.
+func FullText(parsed *gofeed.Feed, cache *ArticleCache) {
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, fullTextFetches)
+	for _, item := range parsed.Items {
+		if item.Link == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(item *gofeed.Item) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			article, err := cache.Article(item.Link)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to extract %s: %s\n", item.Link, err)
+				return
+			}
+			item.Content = article
+		}(item)
+	}
+	wg.Wait()
+}
+
+var (
+	unlikelyArticle = regexp.MustCompile(`(?i)comment|footer|sidebar|nav|menu|share|social|related|promo|advert|sponsor|widget|popup|cookie|banner|masthead|subscribe`)
+	likelyArticle   = regexp.MustCompile(`(?i)article|body|content|entry|main|post|story|text`)
+)
+
+// ExtractArticle finds the main content of an HTML page, in the manner
+// of Readability: paragraphs score points for the elements containing
+// them, and the element with the most wins, discounted for how much of
+// its text is links.
+func ExtractArticle(page io.Reader) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(page)
+	if err != nil {
+		return "", err
+	}
+	doc.Find("script, style, noscript, iframe, form, nav, header, footer, aside, button, svg").Remove()
+
+	scores := map[*html.Node]float64{}
+	candidates := []*goquery.Selection{}
+	addScore := func(s *goquery.Selection, score float64) {
+		if s.Length() == 0 {
+			return
+		}
+		node := s.Get(0)
+		if _, found := scores[node]; !found {
+			scores[node] = classWeight(s)
+			candidates = append(candidates, s)
+		}
+		scores[node] += score
+	}
+	doc.Find("p, pre, td, blockquote").Each(func(_ int, p *goquery.Selection) {
+		text := strings.TrimSpace(p.Text())
+		if len(text) < 25 {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")) + minFloat(float64(len(text))/100, 3)
+		addScore(p.Parent(), score)
+		addScore(p.Parent().Parent(), score/2)
+	})
+
+	var best *goquery.Selection
+	bestScore := 0.0
+	for _, s := range candidates {
+		score := scores[s.Get(0)] * (1 - linkDensity(s))
+		if best == nil || score > bestScore {
+			best, bestScore = s, score
+		}
+	}
+	if best == nil || len(strings.TrimSpace(best.Text())) < minArticleLength {
+		return "", fmt.Errorf("no article found")
+	}
+	best.Find("*").Each(func(_ int, s *goquery.Selection) {
+		id := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+		if unlikelyArticle.MatchString(id) && !likelyArticle.MatchString(id) {
+			s.Remove()
+		}
+	})
+	article, err := best.Html()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(article), nil
+}
+
+// classWeight judges an element by its class and id.
+func classWeight(s *goquery.Selection) float64 {
+	weight := 0.0
+	for _, name := range []string{s.AttrOr("class", ""), s.AttrOr("id", "")} {
+		if name == "" {
+			continue
+		}
+		if unlikelyArticle.MatchString(name) {
+			weight -= 25
+		}
+		if likelyArticle.MatchString(name) {
+			weight += 25
+		}
+	}
+	if goquery.NodeName(s) == "article" {
+		weight += 25
+	}
+	return weight
+}
+
+// linkDensity is the fraction of an element's text which is in links.
+func linkDensity(s *goquery.Selection) float64 {
+	total := len(s.Text())
+	if total == 0 {
+		return 1
+	}
+	links := 0
+	s.Find("a").Each(func(_ int, a *goquery.Selection) {
+		links += len(a.Text())
+	})
+	return float64(links) / float64(total)
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,85 @@
+package feed_test
+
+import (
+	"github.com/mmcdole/gofeed"
+	"github.com/snark/darling/pkg/feed"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestExtractArticle(t *testing.T) {
+	page, _ := os.Open("../../testdata/article.html")
+	defer page.Close()
+	article, err := feed.ExtractArticle(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"in retreat", "building small sites", "having a moment"} {
+		if !strings.Contains(article, want) {
+			t.Errorf("article is missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"Popular this week", "Share on Twitter", "Great article", "trackPageview", "Copyright"} {
+		if strings.Contains(article, unwanted) {
+			t.Errorf("article includes %q", unwanted)
+		}
+	}
+}
+
+func TestFullText(t *testing.T) {
+	page, _ := ioutil.ReadFile("../../testdata/article.html")
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Path == "/r/article" {
+			http.Redirect(w, r, "/posts/article", http.StatusFound)
+			return
+		}
+		w.Write(page)
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "darling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cache := feed.NewArticleCache(dir)
+
+	for run := 0; run < 2; run++ {
+		parsed := &gofeed.Feed{Items: []*gofeed.Item{
+			{Link: server.URL + "/r/article", Content: "A teaser"},
+			{Link: server.URL + "/missing", Content: "Another teaser"},
+		}}
+		feed.FullText(parsed, cache)
+		if !strings.Contains(parsed.Items[0].Content, "hand-built web") {
+			t.Errorf("got %q instead of the article", parsed.Items[0].Content)
+		}
+		// Links are relative to the article's page, after redirects,
+		// rather than to the feed it was found in
+		for _, want := range []string{
+			`href="` + server.URL + `/posts/small-sites.html"`,
+			`src="` + server.URL + `/images/garden.jpg"`,
+		} {
+			if !strings.Contains(parsed.Items[0].Content, want) {
+				t.Errorf("article is missing %s", want)
+			}
+		}
+		if parsed.Items[1].Content != "Another teaser" {
+			t.Errorf("got %q for an article which couldn't be fetched", parsed.Items[1].Content)
+		}
+	}
+	// The second run fetches nothing, having remembered both the article
+	// and the failure
+	if fetches != 3 {
+		t.Errorf("got %d fetches, want 3", fetches)
+	}
+}
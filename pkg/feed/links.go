@@ -0,0 +1,259 @@
+package feed
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mmcdole/gofeed"
+	"github.com/snark/darling/pkg/output"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTrackingParams are query parameters added to links only to track
+// who followed them.
+var DefaultTrackingParams = []string{
+	"utm_*", "fbclid", "gclid", "dclid", "msclkid", "mc_cid", "mc_eid",
+	"igshid", "yclid", "_hsenc", "_hsmi", "mkt_tok", "ref_src",
+}
+
+// How long a resolved redirect is remembered after it was last needed
+const redirectExpiry = 90 * 24 * time.Hour
+
+// How many links are resolved at once for a single feed
+const redirectFetches = 4
+
+// LinkCleaner normalizes the links in items, so that the same article
+// has the same link wherever it came from.
+type LinkCleaner struct {
+	// Params are the query parameters removed from links. A trailing *
+	// matches any suffix, as in "utm_*".
+	Params []string
+	// Redirects, if set, is used to follow links to where they redirect.
+	Redirects *RedirectCache
+}
+
+// Clean normalizes each item's link, and those of the anchors in its
+// description and content.
+func (cleaner *LinkCleaner) Clean(parsed *gofeed.Feed) {
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, redirectFetches)
+	for _, item := range parsed.Items {
+		wg.Add(1)
+		go func(item *gofeed.Item) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			item.Link = cleaner.CleanURL(item.Link)
+			item.Description = cleaner.cleanAnchors(item.Description)
+			item.Content = cleaner.cleanAnchors(item.Content)
+		}(item)
+	}
+	wg.Wait()
+}
+
+// CleanURL follows redirects if asked to, replaces AMP pages with the
+// pages they stand in for, and removes tracking parameters. Links which
+// don't need cleaning are returned as they are.
+func (cleaner *LinkCleaner) CleanURL(link string) string {
+	if link == "" {
+		return link
+	}
+	if cleaner.Redirects != nil {
+		link = cleaner.Redirects.Resolve(link)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	changed := deAMP(u)
+	if u.RawQuery != "" {
+		kept := []string{}
+		for _, param := range strings.Split(u.RawQuery, "&") {
+			key := param
+			if i := strings.Index(param, "="); i >= 0 {
+				key = param[:i]
+			}
+			if k, err := url.QueryUnescape(key); err == nil {
+				key = k
+			}
+			if cleaner.tracking(strings.ToLower(key)) {
+				changed = true
+			} else {
+				kept = append(kept, param)
+			}
+		}
+		u.RawQuery = strings.Join(kept, "&")
+	}
+	if !changed {
+		return link
+	}
+	return u.String()
+}
+
+func (cleaner *LinkCleaner) tracking(key string) bool {
+	for _, param := range cleaner.Params {
+		param = strings.ToLower(param)
+		if strings.HasSuffix(param, "*") {
+			if strings.HasPrefix(key, strings.TrimSuffix(param, "*")) {
+				return true
+			}
+		} else if key == param {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanAnchors cleans the href of every anchor in an HTML fragment.
+func (cleaner *LinkCleaner) cleanAnchors(fragment string) string {
+	if !strings.Contains(strings.ToLower(fragment), "<a") {
+		return fragment
+	}
+	return rewriteAttrs(fragment, func(tag, key string) bool {
+		return tag == "a" && key == "href"
+	}, cleaner.CleanURL)
+}
+
+// deAMP rewrites an AMP page's URL to that of the page it's a version
+// of, reporting whether it did. Pages served from Google's AMP caches
+// are taken back to their own sites, and a trailing /amp/ is dropped.
+// Other mentions of AMP are left alone, since there's no telling them
+// from pages that are just about amps, or amp.dev.
+func deAMP(u *url.URL) bool {
+	changed := false
+	host := strings.ToLower(u.Hostname())
+	cached := ""
+	if (host == "google.com" || host == "www.google.com") && strings.HasPrefix(u.Path, "/amp/") {
+		cached = strings.TrimPrefix(u.Path, "/amp/")
+	} else if strings.HasSuffix(host, ".cdn.ampproject.org") {
+		for _, prefix := range []string{"/c/", "/v/"} {
+			if strings.HasPrefix(u.Path, prefix) {
+				cached = strings.TrimPrefix(u.Path, prefix)
+			}
+		}
+	}
+	if cached != "" {
+		scheme := "http"
+		if strings.HasPrefix(cached, "s/") {
+			scheme, cached = "https", strings.TrimPrefix(cached, "s/")
+		}
+		parts := strings.SplitN(cached, "/", 2)
+		u.Scheme, u.Host, u.Path, u.RawPath = scheme, parts[0], "/", ""
+		if len(parts) == 2 {
+			u.Path += parts[1]
+		}
+		changed = true
+	}
+
+	// example.com/story/amp/, but not example.com/tags/amp/
+	segments := strings.Split(strings.TrimSuffix(u.Path, "/amp/"), "/")
+	if strings.HasSuffix(u.Path, "/amp/") && len(segments) > 1 && !taxonomies[strings.ToLower(segments[len(segments)-1])] {
+		u.Path, u.RawPath = strings.TrimSuffix(u.Path, "amp/"), ""
+		changed = true
+	}
+	return changed
+}
+
+// taxonomies are the path segments sites list posts under, where amp is
+// a topic rather than a page's AMP version.
+var taxonomies = map[string]bool{
+	"tag":        true,
+	"tags":       true,
+	"topic":      true,
+	"topics":     true,
+	"category":   true,
+	"categories": true,
+	"search":     true,
+}
+
+type resolvedLink struct {
+	URL     string    `json:"url"`
+	Checked time.Time `json:"checked"`
+}
+
+// RedirectCache remembers where links redirect to. It's kept as JSON, and
+// forgets links it hasn't been asked about for a while.
+type RedirectCache struct {
+	path  string
+	mu    sync.Mutex
+	links map[string]resolvedLink
+}
+
+// OpenRedirectCache reads the cache at path, which needn't exist yet.
+func OpenRedirectCache(path string) (*RedirectCache, error) {
+	cache := &RedirectCache{path: path, links: map[string]resolvedLink{}}
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, &cache.links); err != nil {
+		return nil, fmt.Errorf("Unable to read %s: %s", path, err)
+	}
+	return cache, nil
+}
+
+// Resolve returns where link ends up after any redirects, asking with a
+// HEAD request if it isn't already known. Links which can't be checked
+// are returned as they are.
+func (cache *RedirectCache) Resolve(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return link
+	}
+	now := time.Now()
+	cache.mu.Lock()
+	resolved, found := cache.links[link]
+	cache.mu.Unlock()
+	// Redirects can change, so they're checked again once they expire
+	if found && now.Sub(resolved.Checked) < redirectExpiry {
+		return resolved.URL
+	}
+
+	req, err := newRequest("HEAD", link)
+	if err != nil {
+		return link
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// Perhaps it'll work next time
+		return link
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		// Perhaps it's down for now, or doesn't allow HEAD
+		return link
+	}
+	target := resp.Request.URL.String()
+	cache.mu.Lock()
+	cache.links[link] = resolvedLink{URL: target, Checked: now}
+	cache.mu.Unlock()
+	return target
+}
+
+// Save writes the cache back out.
+func (cache *RedirectCache) Save() error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cutoff := time.Now().Add(-redirectExpiry)
+	for link, resolved := range cache.links {
+		if resolved.Checked.Before(cutoff) {
+			delete(cache.links, link)
+		}
+	}
+	buf, err := json.Marshal(cache.links)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cache.path), 0755); err != nil {
+		return err
+	}
+	return output.WriteFile(cache.path, string(buf))
+}
@@ -0,0 +1,137 @@
+package feed_test
+
+import (
+	"fmt"
+	"github.com/mmcdole/gofeed"
+	"github.com/snark/darling/pkg/feed"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCleanURL(t *testing.T) {
+	cleaner := &feed.LinkCleaner{Params: append(feed.DefaultTrackingParams, "ref")}
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"https://example.com/post?utm_source=rss&utm_medium=feed", "https://example.com/post"},
+		{"https://example.com/post?id=3&fbclid=abc&page=2", "https://example.com/post?id=3&page=2"},
+		{"https://example.com/post?ref=hn", "https://example.com/post"},
+		{"https://example.com/post?b=2&a=1", "https://example.com/post?b=2&a=1"},
+		{"https://www.google.com/amp/s/example.com/2019/story.html", "https://example.com/2019/story.html"},
+		{"https://example-com.cdn.ampproject.org/c/s/example.com/story", "https://example.com/story"},
+		{"https://example.com/2019/story/amp/", "https://example.com/2019/story/"},
+		{"https://example.com/story/amp/?utm_campaign=x", "https://example.com/story/"},
+		{"https://amp.dev/documentation/", "https://amp.dev/documentation/"},
+		{"https://en.wikipedia.org/wiki/Amp", "https://en.wikipedia.org/wiki/Amp"},
+		{"https://example.com/tags/amp/", "https://example.com/tags/amp/"},
+		{"https://example.com/amp/", "https://example.com/amp/"},
+		{"https://example.com/products/amp", "https://example.com/products/amp"},
+		{"https://example.com/amp/story", "https://example.com/amp/story"},
+		{"https://example.com/examples/amplifiers", "https://example.com/examples/amplifiers"},
+		{"/relative?utm_source=x", "/relative"},
+	}
+	for _, c := range cases {
+		if got := cleaner.CleanURL(c.in); got != c.want {
+			t.Errorf("CleanURL(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestLinkCleanerRedirects(t *testing.T) {
+	var heads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/r/1" {
+			atomic.AddInt32(&heads, 1)
+			http.Redirect(w, r, "/article?utm_source=newsletter", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "darling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "redirects.json")
+
+	for run := 0; run < 2; run++ {
+		redirects, err := feed.OpenRedirectCache(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cleaner := &feed.LinkCleaner{Params: feed.DefaultTrackingParams, Redirects: redirects}
+		parsed := &gofeed.Feed{Items: []*gofeed.Item{{
+			Link:        server.URL + "/r/1",
+			Description: `<p class="x">Read <a href="` + server.URL + `/r/1">it</a> or <a href="/other">this</a>.</p>`,
+		}}}
+		cleaner.Clean(parsed)
+		if want := server.URL + "/article"; parsed.Items[0].Link != want {
+			t.Errorf("got link %q, want %q", parsed.Items[0].Link, want)
+		}
+		want := `<p class="x">Read <a href="` + server.URL + `/article">it</a> or <a href="/other">this</a>.</p>`
+		if parsed.Items[0].Description != want {
+			t.Errorf("got description %q, want %q", parsed.Items[0].Description, want)
+		}
+		if err := redirects.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if heads != 1 {
+		t.Errorf("followed the redirect %d times, want once", heads)
+	}
+}
+
+func TestRedirectCacheExpiry(t *testing.T) {
+	heads := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		heads[r.URL.Path]++
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		case "/gone":
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "darling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "redirects.json")
+	// Resolved long ago, to somewhere it no longer goes
+	stale := time.Now().Add(-100 * 24 * time.Hour).Format(time.RFC3339)
+	cached := fmt.Sprintf(`{%q: {"url": "https://example.com/elsewhere", "checked": %q}}`, server.URL+"/old", stale)
+	if err := ioutil.WriteFile(path, []byte(cached), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for run := 0; run < 2; run++ {
+		redirects, err := feed.OpenRedirectCache(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := redirects.Resolve(server.URL + "/old"); got != server.URL+"/new" {
+			t.Errorf("run %d: got %s for an expired redirect", run, got)
+		}
+		if got := redirects.Resolve(server.URL + "/gone"); got != server.URL+"/gone" {
+			t.Errorf("run %d: got %s for a missing page", run, got)
+		}
+		if err := redirects.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The fresh answer is remembered, but not the error
+	if heads["/old"] != 1 {
+		t.Errorf("resolved the expired redirect %d times, want once", heads["/old"])
+	}
+	if heads["/gone"] != 2 {
+		t.Errorf("checked the missing page %d times, want twice", heads["/gone"])
+	}
+}
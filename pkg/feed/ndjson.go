@@ -0,0 +1,60 @@
+package feed
+
+import (
+	"encoding/json"
+	"github.com/mmcdole/gofeed"
+	"github.com/snark/darling/pkg/output"
+	"io"
+)
+
+// ParseNDJSON reads items written by darling's NDJSON output back in,
+// regrouping them into one feed per source. Items without a source end
+// up together in a feed of their own.
+func ParseNDJSON(r io.Reader) ([]*gofeed.Feed, error) {
+	decoder := json.NewDecoder(r)
+	parsed := []*gofeed.Feed{}
+	bySource := map[output.Source]*gofeed.Feed{}
+	for {
+		var j output.JSONItem
+		err := decoder.Decode(&j)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var source output.Source
+		if j.Source != nil {
+			source = *j.Source
+		}
+		f, found := bySource[source]
+		if !found {
+			f = &gofeed.Feed{
+				Title:    source.Title,
+				FeedLink: source.URL,
+				Link:     source.Link,
+				FeedType: "ndjson",
+			}
+			if source.ID != "" {
+				f.Custom = map[string]string{"id": source.ID}
+			}
+			bySource[source] = f
+			parsed = append(parsed, f)
+		}
+		item := &gofeed.Item{
+			GUID:            j.ID,
+			Title:           j.Title,
+			Link:            j.Link,
+			Description:     j.Description,
+			Content:         j.Content,
+			PublishedParsed: j.Published,
+			UpdatedParsed:   j.Updated,
+			Categories:      j.Categories,
+		}
+		if j.Enclosure != nil {
+			item.Enclosures = []*gofeed.Enclosure{{URL: j.Enclosure.URL, Type: j.Enclosure.Type, Length: j.Enclosure.Length}}
+		}
+		f.Items = append(f.Items, item)
+	}
+	return parsed, nil
+}
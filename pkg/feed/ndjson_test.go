@@ -0,0 +1,97 @@
+package feed_test
+
+import (
+	"github.com/gorilla/feeds"
+	"github.com/snark/darling/pkg/feed"
+	"github.com/snark/darling/pkg/output"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNDJSONRoundTrip(t *testing.T) {
+	published := time.Date(2019, 10, 12, 16, 25, 0, 0, time.UTC)
+	updated := published.Add(time.Hour)
+	lobsters := &output.Source{URL: "https://lobste.rs/rss", Title: "Lobsters", Link: "https://lobste.rs/"}
+	waxy := &output.Source{URL: "https://waxy.org/feed/", Title: "Waxy.org", ID: "tag:waxy.org,2019:feed"}
+	item := func(id string, source *output.Source) *output.Item {
+		return &output.Item{
+			Item: &feeds.Item{
+				Id:      id,
+				Title:   "Item " + id,
+				Link:    &feeds.Link{Href: "https://example.com/" + id},
+				Created: published,
+			},
+			Source: source,
+		}
+	}
+	outfeed := &output.Feed{Items: []*output.Item{
+		item("1", lobsters),
+		item("2", waxy),
+		item("3", nil),
+		item("4", lobsters),
+	}}
+	outfeed.Items[0].Updated = updated
+	outfeed.Items[0].Categories = []string{"go"}
+	outfeed.Items[3].Enclosure = &feeds.Enclosure{Url: "https://example.com/4.mp3", Type: "audio/mpeg", Length: "1234"}
+
+	ndjson, err := output.FeedToNDJSON(outfeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := feed.ParseNDJSON(strings.NewReader(ndjson))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One feed per source, in the order they're first seen
+	if len(parsed) != 3 {
+		t.Fatalf("got %d feeds, want 3", len(parsed))
+	}
+	wantItems := [][]string{{"1", "4"}, {"2"}, {"3"}}
+	for i, f := range parsed {
+		ids := []string{}
+		for _, item := range f.Items {
+			ids = append(ids, item.GUID)
+		}
+		if strings.Join(ids, ",") != strings.Join(wantItems[i], ",") {
+			t.Errorf("feed %d has items %v, want %v", i, ids, wantItems[i])
+		}
+	}
+	if parsed[0].Title != "Lobsters" || parsed[0].FeedLink != "https://lobste.rs/rss" || parsed[0].Link != "https://lobste.rs/" {
+		t.Errorf("got source %s at %s", parsed[0].Title, parsed[0].FeedLink)
+	}
+	if parsed[1].Custom["id"] != "tag:waxy.org,2019:feed" {
+		t.Errorf("lost the feed's id")
+	}
+	if parsed[2].Title != "" || parsed[2].FeedLink != "" {
+		t.Errorf("items without a source got one: %s at %s", parsed[2].Title, parsed[2].FeedLink)
+	}
+
+	undated, _ := feed.NewUndated("drop", nil)
+	items := feed.ProcessItems(parsed[0], nil, undated)
+	if len(items) != 2 {
+		t.Fatalf("got %d items back, want 2", len(items))
+	}
+	first, last := items[0], items[1]
+	if first.Id != "1" || first.Title != "Item 1" || first.Link.Href != "https://example.com/1" {
+		t.Errorf("got item %s, %q at %s", first.Id, first.Title, first.Link.Href)
+	}
+	if !first.Created.Equal(published) || !first.Updated.Equal(updated) {
+		t.Errorf("got dates %s and %s", first.Created, first.Updated)
+	}
+	if first.DatedNow {
+		t.Errorf("item with its own date was dated now")
+	}
+	if len(first.Categories) != 1 || first.Categories[0] != "go" {
+		t.Errorf("got categories %v", first.Categories)
+	}
+	if first.Source == nil || first.Source.URL != lobsters.URL || first.Source.Title != lobsters.Title {
+		t.Errorf("got source %+v", first.Source)
+	}
+	if last.Enclosure == nil || last.Enclosure.Url != "https://example.com/4.mp3" || last.Enclosure.Length != "1234" {
+		t.Errorf("got enclosure %+v", last.Enclosure)
+	}
+	if items := feed.ProcessItems(parsed[2], nil, undated); len(items) != 1 || items[0].Source.URL != "" {
+		t.Errorf("got %d items without a source", len(items))
+	}
+}
@@ -0,0 +1,160 @@
+package feed
+
+import (
+	"github.com/mmcdole/gofeed"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheInfo is what a server told us about a feed the last time we
+// fetched it: validators for asking whether it has changed, and how long
+// it may be considered fresh.
+type CacheInfo struct {
+	ETag         string
+	LastModified string
+	Expires      time.Time
+}
+
+var client = &http.Client{Timeout: time.Minute}
+
+// userAgent is what darling calls itself to servers, the same as gofeed's
+// own fetching does.
+const userAgent = "Gofeed/1.0"
+
+// newRequest makes a request to send with client.
+func newRequest(method, url string) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	return req, nil
+}
+
+// FetchIfChanged fetches a feed, sending the validators from a previous
+// fetch. If the server reports that the feed hasn't changed, the returned
+// feed is nil.
+func FetchIfChanged(url string, prev CacheInfo) (*gofeed.Feed, CacheInfo, error) {
+	body, info, err := GetIfChanged(url, prev)
+	if err != nil || body == nil {
+		return nil, info, err
+	}
+	parsed, err := parse(body)
+	return parsed, info, err
+}
+
+// GetIfChanged fetches any URL the way FetchIfChanged fetches feeds,
+// returning a nil body if it hasn't changed.
+func GetIfChanged(url string, prev CacheInfo) ([]byte, CacheInfo, error) {
+	req, err := newRequest("GET", url)
+	if err != nil {
+		return nil, prev, err
+	}
+	if prev.ETag != "" {
+		req.Header.Set("If-None-Match", prev.ETag)
+	}
+	if prev.LastModified != "" {
+		req.Header.Set("If-Modified-Since", prev.LastModified)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, prev, err
+	}
+	defer resp.Body.Close()
+
+	info := CacheInfo{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Expires:      expiry(resp.Header, time.Now()),
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		// A 304 needn't repeat the validators
+		if info.ETag == "" {
+			info.ETag = prev.ETag
+		}
+		if info.LastModified == "" {
+			info.LastModified = prev.LastModified
+		}
+		return nil, info, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, prev, gofeed.HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		}
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, prev, err
+	}
+	return body, info, nil
+}
+
+// expiry works out when a response stops being fresh, preferring
+// Cache-Control's max-age over Expires. The zero time means it may be
+// fetched again at any time.
+func expiry(header http.Header, now time.Time) time.Time {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.TrimSpace(strings.ToLower(directive))
+		if directive == "no-cache" || directive == "no-store" {
+			return time.Time{}
+		}
+		if strings.HasPrefix(directive, "max-age=") {
+			seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err == nil {
+				return now.Add(time.Duration(seconds) * time.Second)
+			}
+		}
+	}
+	if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+		return expires
+	}
+	return time.Time{}
+}
+
+// NextPoll works out when a feed should next be fetched. That's at least
+// interval from now, and no sooner than the feed's <ttl> or its HTTP
+// freshness allow; the result is then pushed past any hours or days the
+// feed asks to be skipped.
+func NextPoll(f *gofeed.Feed, info CacheInfo, interval time.Duration, now time.Time) time.Time {
+	var custom map[string]string
+	if f != nil {
+		custom = f.Custom
+	}
+	wait := interval
+	if ttl, err := strconv.Atoi(custom["ttl"]); err == nil {
+		if d := time.Duration(ttl) * time.Minute; d > wait {
+			wait = d
+		}
+	}
+	next := now.Add(wait)
+	if info.Expires.After(next) {
+		next = info.Expires
+	}
+
+	skipHours := map[int]bool{}
+	for _, h := range strings.Split(custom["skipHours"], ",") {
+		if hour, err := strconv.Atoi(strings.TrimSpace(h)); err == nil {
+			skipHours[hour%24] = true
+		}
+	}
+	skipDays := map[string]bool{}
+	for _, d := range strings.Split(custom["skipDays"], ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			skipDays[strings.ToLower(d)] = true
+		}
+	}
+	// skipHours are in GMT. A week of hours is enough to get past any
+	// combination, even if the feed asks to skip everything.
+	for i := 0; i < 24*7; i++ {
+		utc := next.UTC()
+		if !skipHours[utc.Hour()] && !skipDays[strings.ToLower(utc.Weekday().String())] {
+			break
+		}
+		next = utc.Truncate(time.Hour).Add(time.Hour)
+	}
+	return next
+}
@@ -0,0 +1,75 @@
+package feed_test
+
+import (
+	"fmt"
+	"github.com/snark/darling/pkg/feed"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func rssWithHints(hints string) string {
+	return fmt.Sprintf(`<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Hints</title><link>https://example.com/</link>
+<description>Scheduling hints</description>%s</channel></rss>`, hints)
+}
+
+func TestNextPoll(t *testing.T) {
+	// A Saturday, in UTC
+	now, _ := time.Parse(time.RFC3339, "2019-10-12T16:25:00Z")
+	var tests = []struct {
+		name     string
+		hints    string
+		cache    feed.CacheInfo
+		expected string
+	}{
+		{"no hints", "", feed.CacheInfo{}, "2019-10-12T16:55:00Z"},
+		{"short ttl", "<ttl>10</ttl>", feed.CacheInfo{}, "2019-10-12T16:55:00Z"},
+		{"long ttl", "<ttl>120</ttl>", feed.CacheInfo{}, "2019-10-12T18:25:00Z"},
+		{"expires", "", feed.CacheInfo{Expires: now.Add(time.Hour)}, "2019-10-12T17:25:00Z"},
+		{"skip hours", "<skipHours><hour>16</hour><hour>17</hour></skipHours>", feed.CacheInfo{}, "2019-10-12T18:00:00Z"},
+		{"skip days", "<skipDays><day>Saturday</day></skipDays>", feed.CacheInfo{}, "2019-10-13T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := feed.ParseFromString(rssWithHints(tt.hints))
+			if err != nil {
+				t.Fatal(err)
+			}
+			next := feed.NextPoll(f, tt.cache, 30*time.Minute, now)
+			expected, _ := time.Parse(time.RFC3339, tt.expected)
+			if !next.Equal(expected) {
+				t.Errorf("got %s, want %s", next.Format(time.RFC3339), tt.expected)
+			}
+		})
+	}
+}
+
+func TestNextPollSkipEverything(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2019-10-12T16:25:00Z")
+	days := ""
+	for _, d := range []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"} {
+		days += "<day>" + d + "</day>"
+	}
+	f, _ := feed.ParseFromString(rssWithHints("<skipDays>" + days + "</skipDays>"))
+	next := feed.NextPoll(f, feed.CacheInfo{}, time.Minute, now)
+	if next.After(now.Add(8 * 24 * time.Hour)) {
+		t.Errorf("got %s, expected to give up skipping within a week", next.Format(time.RFC3339))
+	}
+}
+
+func TestGetIfChangedUserAgent(t *testing.T) {
+	agent := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agent = r.UserAgent()
+		fmt.Fprint(w, rssWithHints(""))
+	}))
+	defer server.Close()
+	if _, _, err := feed.GetIfChanged(server.URL, feed.CacheInfo{}); err != nil {
+		t.Fatal(err)
+	}
+	if agent != "Gofeed/1.0" {
+		t.Errorf("got User-Agent %q", agent)
+	}
+}
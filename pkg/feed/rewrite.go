@@ -0,0 +1,88 @@
+package feed
+
+import (
+	"fmt"
+	"github.com/gorilla/feeds"
+	"github.com/mmcdole/gofeed"
+	"regexp"
+	"strings"
+)
+
+// Rewrite replaces whatever Pattern matches in one field of an item with
+// Replacement, in which $1 or ${name} stand for the pattern's groups.
+type Rewrite struct {
+	// Field is 'title', 'description', 'content' or 'link'.
+	Field       string
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// NewRewrite builds a rewrite rule, checking its field and pattern.
+func NewRewrite(field, pattern, replacement string) (*Rewrite, error) {
+	field = strings.ToLower(strings.TrimSpace(field))
+	switch field {
+	case "title", "description", "content", "link":
+	default:
+		return nil, fmt.Errorf("Unknown field %q; use title, description, content or link", field)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid rewrite pattern %q: %s", pattern, err)
+	}
+	return &Rewrite{Field: field, Pattern: re, Replacement: replacement}, nil
+}
+
+// ParseRewrite reads a rule given as FIELD=s/PATTERN/REPLACEMENT/, in the
+// manner of sed. Any character can stand in for the slashes, as in
+// link=s|//mirror.example.com/|//example.com/|.
+func ParseRewrite(spec string) (*Rewrite, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || len(parts[1]) < 2 || parts[1][0] != 's' {
+		return nil, fmt.Errorf("Rewrite %q should look like title=s/PATTERN/REPLACEMENT/", spec)
+	}
+	delimiter := parts[1][1:2]
+	sub := strings.Split(parts[1][2:], delimiter)
+	if len(sub) != 3 || sub[2] != "" {
+		return nil, fmt.Errorf("Rewrite %q should look like %s=s%sPATTERN%sREPLACEMENT%s", spec, parts[0], delimiter, delimiter, delimiter)
+	}
+	return NewRewrite(parts[0], sub[0], sub[1])
+}
+
+func (rule *Rewrite) apply(s *string) {
+	*s = rule.Pattern.ReplaceAllString(*s, rule.Replacement)
+}
+
+// Item rewrites a parsed item, before it's filtered.
+func (rule *Rewrite) Item(i *gofeed.Item) {
+	switch rule.Field {
+	case "title":
+		rule.apply(&i.Title)
+	case "description":
+		rule.apply(&i.Description)
+	case "content":
+		rule.apply(&i.Content)
+	case "link":
+		rule.apply(&i.Link)
+	}
+}
+
+// OutputItem rewrites an item which has already been filtered.
+func (rule *Rewrite) OutputItem(i *feeds.Item) {
+	switch rule.Field {
+	case "title":
+		rule.apply(&i.Title)
+	case "description":
+		rule.apply(&i.Description)
+	case "content":
+		rule.apply(&i.Content)
+	case "link":
+		if i.Link != nil {
+			rule.apply(&i.Link.Href)
+		}
+	}
+}
+
+// String is the rule as ParseRewrite reads it.
+func (rule *Rewrite) String() string {
+	return fmt.Sprintf("%s=s/%s/%s/", rule.Field, rule.Pattern, rule.Replacement)
+}
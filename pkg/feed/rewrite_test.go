@@ -0,0 +1,56 @@
+package feed_test
+
+import (
+	"github.com/gorilla/feeds"
+	"github.com/mmcdole/gofeed"
+	"github.com/snark/darling/pkg/feed"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	sponsored, err := feed.ParseRewrite(`title=s/^\[Sponsored\]\s*//`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mirror, err := feed.ParseRewrite(`link=s|//mirror\.(\w+)\.com/|//www.$1.com/|`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	footer, err := feed.NewRewrite("content", `<p>The post .*? appeared first on .*?</p>$`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item := &gofeed.Item{
+		Title:   "[Sponsored] A new phone",
+		Link:    "https://mirror.example.com/phone",
+		Content: "<p>It's a phone.</p><p>The post A new phone appeared first on Example.</p>",
+	}
+	for _, rule := range []*feed.Rewrite{sponsored, mirror, footer} {
+		rule.Item(item)
+	}
+	if item.Title != "A new phone" {
+		t.Errorf("got title %q", item.Title)
+	}
+	if item.Link != "https://www.example.com/phone" {
+		t.Errorf("got link %q", item.Link)
+	}
+	if item.Content != "<p>It's a phone.</p>" {
+		t.Errorf("got content %q", item.Content)
+	}
+
+	out := &feeds.Item{Title: "[Sponsored] Another phone", Link: &feeds.Link{Href: "https://mirror.example.com/other"}}
+	sponsored.OutputItem(out)
+	mirror.OutputItem(out)
+	if out.Title != "Another phone" || out.Link.Href != "https://www.example.com/other" {
+		t.Errorf("got %q at %q", out.Title, out.Link.Href)
+	}
+}
+
+func TestRewriteInvalid(t *testing.T) {
+	for _, spec := range []string{"title", "title=s/a/b", "title=y/a/b/", "author=s/a/b/", "title=s/(/x/"} {
+		if _, err := feed.ParseRewrite(spec); err == nil {
+			t.Errorf("ParseRewrite(%q) should have failed", spec)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package feed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/mmcdole/gofeed"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Undated decides the date of an item which has neither a published nor
+// an updated date of its own. The item is dropped if ok is false. A zero
+// date leaves the item undated while it's filtered, so a date filter
+// won't pass it, and it's then dated at the time of the run.
+type Undated func(parsed *gofeed.Feed, id string) (date time.Time, ok bool)
+
+// NewUndated builds the policy for undated items named by policy:
+//   - now: they're dated at the time of the run (the default)
+//   - drop: they're left out
+//   - first-seen: they're dated when darling first saw them, as recorded
+//     in store
+//   - feed-updated: they take the feed's own updated or published date,
+//     or are dropped if it has neither
+func NewUndated(policy string, store *SeenStore) (Undated, error) {
+	switch policy {
+	case "", "now":
+		return func(*gofeed.Feed, string) (time.Time, bool) {
+			return time.Time{}, true
+		}, nil
+	case "drop":
+		return func(*gofeed.Feed, string) (time.Time, bool) {
+			return time.Time{}, false
+		}, nil
+	case "first-seen":
+		if store == nil {
+			return nil, fmt.Errorf("The first-seen policy needs somewhere to remember items")
+		}
+		return func(_ *gofeed.Feed, id string) (time.Time, bool) {
+			return store.FirstSeen(id, time.Now()), true
+		}, nil
+	case "feed-updated":
+		return func(parsed *gofeed.Feed, _ string) (time.Time, bool) {
+			if parsed.UpdatedParsed != nil {
+				return *parsed.UpdatedParsed, true
+			}
+			if parsed.PublishedParsed != nil {
+				return *parsed.PublishedParsed, true
+			}
+			return time.Time{}, false
+		}, nil
+	}
+	return nil, fmt.Errorf("Unknown policy for undated items: %s", policy)
+}
+
+// ItemID is an item's GUID or, failing that, one derived from its link
+// and title, so that it's the same from one run to the next.
+func ItemID(item *gofeed.Item) string {
+	if item.GUID != "" {
+		return item.GUID
+	}
+	sum := sha256.Sum256([]byte(item.Link + "\x00" + item.Title))
+	return "urn:darling:" + hex.EncodeToString(sum[:16])
+}
+
+// How long an item is remembered after it was last seen
+const seenExpiry = 90 * 24 * time.Hour
+
+type seenTimes struct {
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+}
+
+// SeenStore remembers when items were first seen, keyed by ID. It's kept
+// as a small JSON file; items which haven't been seen for a while are
+// forgotten when it's saved.
+type SeenStore struct {
+	path string
+	mu   sync.Mutex
+	seen map[string]seenTimes
+}
+
+// OpenSeenStore reads the store at path, which needn't exist yet.
+func OpenSeenStore(path string) (*SeenStore, error) {
+	store := &SeenStore{path: path, seen: map[string]seenTimes{}}
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, &store.seen); err != nil {
+		return nil, fmt.Errorf("Unable to read %s: %s", path, err)
+	}
+	return store, nil
+}
+
+// FirstSeen returns when the item was first seen, recording now as that
+// time if it's new.
+func (store *SeenStore) FirstSeen(id string, now time.Time) time.Time {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	times, found := store.seen[id]
+	if !found {
+		times.First = now
+	}
+	times.Last = now
+	store.seen[id] = times
+	return times.First
+}
+
+// Save writes the store back out.
+func (store *SeenStore) Save() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	cutoff := time.Now().Add(-seenExpiry)
+	for id, times := range store.seen {
+		if times.Last.Before(cutoff) {
+			delete(store.seen, id)
+		}
+	}
+	buf, err := json.Marshal(store.seen)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(store.path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(store.path, buf, 0644)
+}
@@ -0,0 +1,143 @@
+package feed_test
+
+import (
+	"github.com/mmcdole/gofeed"
+	"github.com/snark/darling/pkg/feed"
+	"github.com/snark/darling/pkg/filter"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tempPath(t *testing.T, name string) (string, func()) {
+	dir, err := ioutil.TempDir("", "darling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, name), func() { os.RemoveAll(dir) }
+}
+
+func undatedFeed() *gofeed.Feed {
+	updated := time.Date(2019, 10, 12, 16, 25, 0, 0, time.UTC)
+	return &gofeed.Feed{
+		Title:         "Undated",
+		UpdatedParsed: &updated,
+		Items: []*gofeed.Item{
+			{Title: "No date", Link: "https://example.com/1"},
+			{Title: "Updated only", Link: "https://example.com/2", UpdatedParsed: &updated},
+		},
+	}
+}
+
+func TestItemID(t *testing.T) {
+	item := &gofeed.Item{Title: "No GUID", Link: "https://example.com/"}
+	id := feed.ItemID(item)
+	if !strings.HasPrefix(id, "urn:darling:") {
+		t.Errorf("got %s for an item without a GUID", id)
+	}
+	if id != feed.ItemID(&gofeed.Item{Title: "No GUID", Link: "https://example.com/"}) {
+		t.Errorf("generated ID isn't stable")
+	}
+	if id == feed.ItemID(&gofeed.Item{Title: "Another", Link: "https://example.com/"}) {
+		t.Errorf("generated ID doesn't depend on the title")
+	}
+	if guid := feed.ItemID(&gofeed.Item{GUID: "guid", Title: "No GUID"}); guid != "guid" {
+		t.Errorf("got %s for an item with a GUID", guid)
+	}
+}
+
+func TestUndatedPolicies(t *testing.T) {
+	path, cleanup := tempPath(t, "seen.json")
+	defer cleanup()
+	store, _ := feed.OpenSeenStore(path)
+	var tests = []struct {
+		policy string
+		count  int
+	}{
+		{"now", 2},
+		{"drop", 1},
+		{"first-seen", 2},
+		{"feed-updated", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			undated, err := feed.NewUndated(tt.policy, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			items := feed.ProcessItems(undatedFeed(), nil, undated)
+			if len(items) != tt.count {
+				t.Fatalf("got %d items, want %d", len(items), tt.count)
+			}
+			for _, item := range items {
+				if item.Title == "Updated only" && item.Created.Year() != 2019 {
+					t.Errorf("item with only an updated date was dated %s", item.Created)
+				}
+			}
+		})
+	}
+	if _, err := feed.NewUndated("sometimes", store); err == nil {
+		t.Errorf("did not return an error for an unknown policy")
+	}
+}
+
+func TestUndatedSince(t *testing.T) {
+	path, cleanup := tempPath(t, "seen.json")
+	defer cleanup()
+	store, _ := feed.OpenSeenStore(path)
+	since := &filter.Since{When: time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)}
+	// An undated item only gets past --since if its policy gives it a
+	// date worth comparing
+	var tests = []struct {
+		policy string
+		passes bool
+	}{
+		{"now", false},
+		{"drop", false},
+		{"first-seen", true},
+		{"feed-updated", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			undated, err := feed.NewUndated(tt.policy, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			items := feed.ProcessItems(undatedFeed(), []filter.ItemFilter{since}, undated)
+			passed := false
+			for _, item := range items {
+				if item.Title == "No date" {
+					passed = true
+				}
+			}
+			if passed != tt.passes {
+				t.Errorf("undated item passed: %v, want %v", passed, tt.passes)
+			}
+		})
+	}
+}
+
+func TestSeenStore(t *testing.T) {
+	path, cleanup := tempPath(t, "seen.json")
+	defer cleanup()
+	store, err := feed.OpenSeenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := time.Now().Add(-time.Hour).Truncate(time.Second)
+	store.FirstSeen("a", first)
+	store.FirstSeen("a", time.Now())
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := feed.OpenSeenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seen := reopened.FirstSeen("a", time.Now()); !seen.Equal(first) {
+		t.Errorf("got first seen %s, want %s", seen, first)
+	}
+}
@@ -0,0 +1,120 @@
+package filter
+
+// automaton is an Aho-Corasick matcher for a set of literal terms, which
+// finds occurrences of any of them in a single pass over the text,
+// however many terms there are. Terms in literalMode must be whole
+// words, as judged by wholeWord.
+type automaton struct {
+	// classes maps each byte to a column of next; bytes which appear in
+	// no term share column 0.
+	classes [256]int32
+	width   int32
+	// next holds width transitions for each state, with the failure
+	// transitions already folded in.
+	next []int32
+	// ends are the terms ending at each state, and dict is the nearest
+	// state along the failure links which has any (or -1).
+	ends [][]ending
+	dict []int32
+}
+
+type ending struct {
+	index     int
+	length    int
+	wholeWord bool
+}
+
+// newAutomaton builds a matcher for literal and substring terms, matching
+// case exactly. Occurrences are reported by the terms' indexes.
+func newAutomaton(terms []indexedTerm) *automaton {
+	a := &automaton{width: 1}
+	for _, t := range terms {
+		for i := 0; i < len(t.text); i++ {
+			if a.classes[t.text[i]] == 0 {
+				a.classes[t.text[i]] = a.width
+				a.width++
+			}
+		}
+	}
+
+	// Build the trie, using 0 for missing transitions; the root is state
+	// 0, which nothing transitions back to while building.
+	a.next = make([]int32, a.width)
+	a.ends = [][]ending{nil}
+	for _, t := range terms {
+		state := int32(0)
+		for i := 0; i < len(t.text); i++ {
+			class := a.classes[t.text[i]]
+			if a.next[state*a.width+class] == 0 {
+				a.next = append(a.next, make([]int32, a.width)...)
+				a.ends = append(a.ends, nil)
+				a.next[state*a.width+class] = int32(len(a.ends) - 1)
+			}
+			state = a.next[state*a.width+class]
+		}
+		a.ends[state] = append(a.ends[state], ending{t.index, len(t.text), t.mode == literalMode})
+	}
+
+	// Breadth first, point missing transitions where the failure links
+	// would lead, and work out the dictionary links.
+	fail := make([]int32, len(a.ends))
+	a.dict = make([]int32, len(a.ends))
+	a.dict[0] = -1
+	queue := []int32{}
+	for class := int32(0); class < a.width; class++ {
+		if child := a.next[class]; child != 0 {
+			a.dict[child] = -1
+			queue = append(queue, child)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for class := int32(0); class < a.width; class++ {
+			child := a.next[state*a.width+class]
+			target := a.next[fail[state]*a.width+class]
+			if child == 0 {
+				a.next[state*a.width+class] = target
+				continue
+			}
+			fail[child] = target
+			if len(a.ends[target]) > 0 {
+				a.dict[child] = target
+			} else {
+				a.dict[child] = a.dict[target]
+			}
+			queue = append(queue, child)
+		}
+	}
+	return a
+}
+
+// MatchString reports whether any term occurs in text, stopping at the
+// first occurrence.
+func (a *automaton) MatchString(text string) bool {
+	found := false
+	a.each(text, func(int) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+// each calls found with the index of the term for every occurrence in
+// text, until it returns false.
+func (a *automaton) each(text string, found func(index int) bool) {
+	state := int32(0)
+	for end := 0; end < len(text); end++ {
+		state = a.next[state*a.width+a.classes[text[end]]]
+		for s := state; s > 0; s = a.dict[s] {
+			for _, e := range a.ends[s] {
+				if e.wholeWord && !wholeWord(text, end+1-e.length, end+1) {
+					continue
+				}
+				if !found(e.index) {
+					return
+				}
+			}
+		}
+	}
+}
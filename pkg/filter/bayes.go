@@ -0,0 +1,118 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mmcdole/gofeed"
+	"github.com/snark/darling/pkg/output"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// Model is a naive Bayes model of the items someone likes and dislikes,
+// built from the words in them.
+type Model struct {
+	Liked    ClassCounts `json:"liked"`
+	Disliked ClassCounts `json:"disliked"`
+	// Tokens holds how often each word was seen in liked and disliked
+	// items, in that order.
+	Tokens map[string][2]int `json:"tokens"`
+}
+
+// ClassCounts are the number of items seen with a label, and the number
+// of words in them.
+type ClassCounts struct {
+	Items  int `json:"items"`
+	Tokens int `json:"tokens"`
+}
+
+func NewModel() *Model {
+	return &Model{Tokens: map[string][2]int{}}
+}
+
+// LoadModel reads a model saved as JSON.
+func LoadModel(path string) (*Model, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	model := NewModel()
+	if err := json.Unmarshal(buf, model); err != nil {
+		return nil, fmt.Errorf("Unable to read %s: %s", path, err)
+	}
+	return model, nil
+}
+
+// Save writes the model to path as JSON.
+func (model *Model) Save(path string) error {
+	buf, err := json.Marshal(model)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf, 0644)
+}
+
+// Train adds an item to the model as liked or disliked.
+func (model *Model) Train(i gofeed.Item, liked bool) {
+	class := 1
+	counts := &model.Disliked
+	if liked {
+		class = 0
+		counts = &model.Liked
+	}
+	counts.Items++
+	for _, token := range itemTokens(i) {
+		seen := model.Tokens[token]
+		seen[class]++
+		model.Tokens[token] = seen
+		counts.Tokens++
+	}
+}
+
+// Prob is the probability that the item will be liked. Words the model
+// hasn't seen are ignored, and an untrained model doesn't lean either
+// way.
+func (model *Model) Prob(i gofeed.Item) float64 {
+	if model.Liked.Items == 0 && model.Disliked.Items == 0 {
+		return 0.5
+	}
+	// Laplace smoothing keeps one unseen word from deciding everything
+	vocabulary := float64(len(model.Tokens))
+	items := float64(model.Liked.Items + model.Disliked.Items)
+	liked := math.Log(float64(model.Liked.Items+1) / (items + 2))
+	disliked := math.Log(float64(model.Disliked.Items+1) / (items + 2))
+	for _, token := range itemTokens(i) {
+		seen, found := model.Tokens[token]
+		if !found {
+			continue
+		}
+		liked += math.Log(float64(seen[0]+1) / (float64(model.Liked.Tokens) + vocabulary))
+		disliked += math.Log(float64(seen[1]+1) / (float64(model.Disliked.Tokens) + vocabulary))
+	}
+	return 1 / (1 + math.Exp(disliked-liked))
+}
+
+// itemTokens are the words of an item's title, description and content.
+func itemTokens(i gofeed.Item) []string {
+	tokens := tokenize(i.Title)
+	tokens = append(tokens, tokenize(output.PlainText(i.Description))...)
+	if i.Content != i.Description {
+		tokens = append(tokens, tokenize(output.PlainText(i.Content))...)
+	}
+	return tokens
+}
+
+// Classifier passes the items a model thinks are likely to be liked.
+type Classifier struct {
+	Model   *Model
+	MinProb float64
+}
+
+func (filter *Classifier) Match(i gofeed.Item) bool {
+	return filter.Model.Prob(i) >= filter.MinProb
+}
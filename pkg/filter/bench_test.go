@@ -0,0 +1,118 @@
+package filter_test
+
+import (
+	"fmt"
+	"github.com/mmcdole/gofeed"
+	"github.com/snark/darling/pkg/filter"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func testdataItems() []*gofeed.Item {
+	items := []*gofeed.Item{}
+	for _, path := range []string{"../../testdata/lobste.rs.rss", "../../testdata/waxy.org.rss"} {
+		items = append(items, parsedFeedFromFile(path).Items...)
+	}
+	return items
+}
+
+var wordPattern = regexp.MustCompile(`[A-Za-z][A-Za-z']+`)
+
+// testdataTerms picks n terms from the words used in testdata, padded
+// out with made-up words which never match.
+func testdataTerms(n int) []string {
+	seen := map[string]bool{}
+	words := []string{}
+	for _, item := range testdataItems() {
+		for _, word := range wordPattern.FindAllString(item.Title+" "+item.Description, -1) {
+			if !seen[strings.ToLower(word)] {
+				seen[strings.ToLower(word)] = true
+				words = append(words, word)
+			}
+		}
+	}
+	sort.Strings(words)
+	terms := []string{}
+	for i := 0; len(terms) < n; i++ {
+		if i%3 == 0 && i/3 < len(words) {
+			terms = append(terms, words[i/3])
+		} else {
+			terms = append(terms, fmt.Sprintf("zzterm%d", i))
+		}
+	}
+	return terms
+}
+
+// The automaton should agree with a regexp for each term on its own.
+func TestRegexpAgreesWithTerms(t *testing.T) {
+	items := testdataItems()
+	for _, term := range append(testdataTerms(300), "that's", "this weekend", "c", "s") {
+		re := regexp.MustCompile(`(?i)(?:^|\W)` + regexp.QuoteMeta(term) + `(?:\W|$)`)
+		wc, err := filter.NewRegexp([]string{term})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, item := range items {
+			want := re.MatchString(item.Title) || re.MatchString(item.Description) || re.MatchString(item.Content)
+			if got := wc.Match(*item); got != want {
+				t.Errorf("%q on item %d: got %t, want %t", term, i, got, want)
+			}
+		}
+	}
+}
+
+func TestRegexpMixedTerms(t *testing.T) {
+	feed := parsedFeedFromFile("../../testdata/lobste.rs.rss")
+	wc, err := filter.NewRegexp([]string{"nothing-here", `re:\bweek(end)?\b`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wc.Match(*feed.Items[1]) {
+		t.Errorf("got false from a regexp term alongside a literal one")
+	}
+}
+
+func benchmarkRegexp(b *testing.B, n int) {
+	items := testdataItems()
+	wc, _ := filter.NewRegexp(testdataTerms(n))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, item := range items {
+			wc.Match(*item)
+		}
+	}
+}
+
+func BenchmarkRegexp10(b *testing.B)    { benchmarkRegexp(b, 10) }
+func BenchmarkRegexp100(b *testing.B)   { benchmarkRegexp(b, 100) }
+func BenchmarkRegexp1000(b *testing.B)  { benchmarkRegexp(b, 1000) }
+func BenchmarkRegexp10000(b *testing.B) { benchmarkRegexp(b, 10000) }
+
+// Terms which never match make every item a worst case.
+func benchmarkRegexpMisses(b *testing.B, n int) {
+	items := testdataItems()
+	terms := make([]string, n)
+	for i := range terms {
+		terms[i] = fmt.Sprintf("zzterm%d", i)
+	}
+	wc, _ := filter.NewRegexp(terms)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, item := range items {
+			wc.Match(*item)
+		}
+	}
+}
+
+func BenchmarkRegexpMisses10(b *testing.B)    { benchmarkRegexpMisses(b, 10) }
+func BenchmarkRegexpMisses1000(b *testing.B)  { benchmarkRegexpMisses(b, 1000) }
+func BenchmarkRegexpMisses10000(b *testing.B) { benchmarkRegexpMisses(b, 10000) }
+
+func BenchmarkNewRegexp1000(b *testing.B) {
+	terms := testdataTerms(1000)
+	for i := 0; i < b.N; i++ {
+		filter.NewRegexp(terms)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"github.com/mmcdole/gofeed"
 	"regexp"
 	"strconv"
-	"strings"
 	"time"
 )
 
@@ -36,8 +35,9 @@ type Count struct {
 	count int
 }
 
+// Regexp matches items mentioning any of a list of terms.
 type Regexp struct {
-	regexps []*regexp.Regexp
+	terms *termSet
 }
 
 type Since struct {
@@ -71,17 +71,12 @@ func (filter *Count) Match(i gofeed.Item) bool {
 
 // TODO: Does not currently handle item.Categories
 func (filter *Regexp) Match(i gofeed.Item) bool {
-	found := false
-	for _, re := range filter.regexps {
-		if re.MatchString(i.Content) {
-			found = true
-		} else if re.MatchString(i.Title) {
-			found = true
-		} else if re.MatchString(i.Description) {
-			found = true
+	for _, field := range []string{i.Title, i.Description, i.Content} {
+		if filter.terms.match(field) {
+			return true
 		}
 	}
-	return found
+	return false
 }
 
 // TODO: Allow additional arguments allowing us to also check
@@ -96,32 +91,19 @@ func (filter *Since) Match(i gofeed.Item) bool {
 	return false
 }
 
-// TODO: Allow case-sensitive matching?
-// TODO: Log error
-func NewRegexp(words []string) ItemFilter {
-	wildcard := false
+// NewRegexp builds a filter matching items which mention any of words,
+// in any of the modes described by term. A "*" matches everything.
+func NewRegexp(words []string) (ItemFilter, error) {
 	for _, word := range words {
 		if word == "*" {
-			wildcard = true
-			break
+			return &True{}, nil
 		}
 	}
-	if wildcard {
-		return &True{}
-	} else {
-		reSlice := []*regexp.Regexp{}
-		for _, word := range words {
-			word = strings.TrimSpace(word)
-			// Silently discard empty/whitespace strings
-			if word != "" {
-				var re, err = regexp.Compile(`(?i)\b` + word + `\b`)
-				if err == nil {
-					reSlice = append(reSlice, re)
-				}
-			}
-		}
-		return &Regexp{regexps: reSlice}
+	terms, err := compileTerms(words)
+	if err != nil {
+		return nil, err
 	}
+	return &Regexp{terms: terms}, nil
 }
 
 // We want to accept a couple different options here:
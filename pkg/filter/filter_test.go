@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"github.com/mmcdole/gofeed"
 	"github.com/snark/darling/pkg/filter"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -144,7 +147,7 @@ func TestRegexpBasic(t *testing.T) {
 	// We exercise case-insensitivity and word boundaries
 	// in our regexps. This test is matching titles only.
 	feed := parsedFeedFromFile("../../testdata/lobste.rs.rss")
-	re := filter.NewRegexp([]string{
+	re, _ := filter.NewRegexp([]string{
 		"package",
 		"potential",
 		"tcp",
@@ -184,7 +187,7 @@ func TestRegexpBasic(t *testing.T) {
 
 func TestRegexpTitle(t *testing.T) {
 	feed := parsedFeedFromFile("../../testdata/waxy.org.rss")
-	wc := filter.NewRegexp([]string{"gerry"})
+	wc, _ := filter.NewRegexp([]string{"gerry"})
 	ans := wc.Match(*feed.Items[0])
 	if ans != true {
 		t.Errorf("got false from title match")
@@ -193,7 +196,7 @@ func TestRegexpTitle(t *testing.T) {
 
 func TestRegexpDescription(t *testing.T) {
 	feed := parsedFeedFromFile("../../testdata/waxy.org.rss")
-	wc := filter.NewRegexp([]string{"delightfully"})
+	wc, _ := filter.NewRegexp([]string{"delightfully"})
 	ans := wc.Match(*feed.Items[13])
 	if ans != true {
 		t.Errorf("got false from description match")
@@ -202,7 +205,7 @@ func TestRegexpDescription(t *testing.T) {
 
 func TestRegexpContent(t *testing.T) {
 	feed := parsedFeedFromFile("../../testdata/waxy.org.rss")
-	wc := filter.NewRegexp([]string{"amazing creators"})
+	wc, _ := filter.NewRegexp([]string{"amazing creators"})
 	ans := wc.Match(*feed.Items[13])
 	if ans != true {
 		t.Errorf("got false from content match")
@@ -210,7 +213,7 @@ func TestRegexpContent(t *testing.T) {
 }
 
 func TestRegexpMultiWord(t *testing.T) {
-	wc := filter.NewRegexp([]string{
+	wc, _ := filter.NewRegexp([]string{
 		"this weekend",
 	})
 	feed := parsedFeedFromFile("../../testdata/lobste.rs.rss")
@@ -225,7 +228,7 @@ func TestRegexpMultiWord(t *testing.T) {
 }
 
 func TestRegexpWhiteSpace(t *testing.T) {
-	wc := filter.NewRegexp([]string{
+	wc, _ := filter.NewRegexp([]string{
 		"",
 		"  ",
 		"\t",
@@ -240,7 +243,7 @@ func TestRegexpWhiteSpace(t *testing.T) {
 }
 
 func TestRegexpWildcard(t *testing.T) {
-	wc := filter.NewRegexp([]string{
+	wc, _ := filter.NewRegexp([]string{
 		"foo",
 		"*",
 		"bar",
@@ -357,3 +360,286 @@ func TestNewSinceUnparseable(t *testing.T) {
 		}
 	}
 }
+
+func TestReadTerms(t *testing.T) {
+	list := "# Shared blocklist\ncrypto\n\n  nft  # and friends\nc#\n\t# indented comment\nweb3\n"
+	terms, err := filter.ReadTerms(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"crypto", "nft", "c#", "web3"}
+	if !reflect.DeepEqual(terms, want) {
+		t.Errorf("got %q, want %q", terms, want)
+	}
+}
+
+func TestRegexpModes(t *testing.T) {
+	item := gofeed.Item{
+		Title:       "Why C++ and Go? Notes on cryptocurrency",
+		Description: "Written by GOPHERS, at https://example.com/a.b",
+	}
+	var tests = []struct {
+		term string
+		want bool
+	}{
+		{"c++", true},
+		{"C++ and go", true},
+		{"c", true},
+		{"a.b", true},
+		{"a*b", false},
+		{"crypto", false},
+		{"sub:crypto", true},
+		{"glob:crypto*", true},
+		{"glob:g?", true},
+		{"glob:gopher", false},
+		{"glob:https://*", true},
+		{"cs:go", false},
+		{"cs:Go", true},
+		{"cs:sub:GOPHER", true},
+		{"cs:glob:gopher*", false},
+		{`re:\bcrypto`, true},
+		{`re:^why`, true},
+		{`cs:re:^why`, false},
+		{"re:", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			wc, err := filter.NewRegexp([]string{tt.term})
+			if err != nil {
+				if tt.want {
+					t.Fatal(err)
+				}
+				return
+			}
+			if ans := wc.Match(item); ans != tt.want {
+				t.Errorf("got %t, want %t", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegexpInvalid(t *testing.T) {
+	for _, term := range []string{"re:(", "cs:re:[a-", "sub:", "cs:"} {
+		if _, err := filter.NewRegexp([]string{"fine", term}); err == nil {
+			t.Errorf("got no error from %q", term)
+		}
+	}
+}
+
+func TestRegexpUnicode(t *testing.T) {
+	item := gofeed.Item{
+		Title:       "Au café, l'Élysée parle d'efficacité",
+		Description: "東京タワーで新しいGoの勉強会、ＦＵＬＬＷＩＤＴＨ text",
+	}
+	var tests = []struct {
+		term string
+		want bool
+	}{
+		{"café", true},
+		{"CAFÉ", true},
+		{"caf", false},
+		{"cafe", false},
+		{"ai:cafe", true},
+		{"ai:elysee", true},
+		{"cs:ai:elysee", false},
+		{"cs:ai:Elysee", true},
+		{"efficacité", true},
+		{"ﬃcacité", false},
+		{"sub:ﬃcacité", true},
+		{"東京", true},
+		{"タワー", true},
+		{"勉強会", true},
+		{"go", true},
+		{"fullwidth", true},
+		{"glob:l'é*", true},
+		{"glob:élys?e", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			wc, err := filter.NewRegexp([]string{tt.term})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ans := wc.Match(item); ans != tt.want {
+				t.Errorf("got %t, want %t", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeightedTerms(t *testing.T) {
+	item := gofeed.Item{
+		Title:       "Rust and crypto",
+		Description: "Crypto, crypto, crypto. Also cryptography.",
+	}
+	var tests = []struct {
+		terms     []string
+		fields    []string
+		threshold float64
+		score     float64
+		want      bool
+	}{
+		{[]string{"crypto:-2"}, nil, 0, -8, false},
+		{[]string{"crypto:-2"}, nil, -10, -8, true},
+		{[]string{"crypto:-2", "rust:+3"}, nil, 0, -5, false},
+		{[]string{"crypto:-2", "rust:+3"}, []string{"title=4", "description=0.5"}, 0, 1, true},
+		{[]string{"glob:crypto*:-1", "cs:rust:1"}, nil, -5, -5, true},
+		{[]string{"re:crypt:1"}, []string{"title=0"}, 5, 4, false},
+		{[]string{"golang:+1"}, nil, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.terms, ","), func(t *testing.T) {
+			fields, err := filter.ParseFieldWeights(tt.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wt, err := filter.NewWeightedTerms(tt.terms, fields, tt.threshold)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if score := wt.Score(item); score != tt.score {
+				t.Errorf("got score %g, want %g", score, tt.score)
+			}
+			if ans := wt.Match(item); ans != tt.want {
+				t.Errorf("got %t, want %t", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeightedTermsExplain(t *testing.T) {
+	wt, err := filter.NewWeightedTerms([]string{"crypto:-2"}, filter.DefaultFieldWeights, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var explained strings.Builder
+	wt.Explain = &explained
+	item := gofeed.Item{Title: "Crypto news"}
+	// As when an item is matched, scored, and matched again for a route
+	wt.Match(item)
+	wt.Score(item)
+	wt.Match(item)
+	if n := strings.Count(explained.String(), "\n"); n != 1 {
+		t.Errorf("item was explained %d times:\n%s", n, explained.String())
+	}
+	wt.Match(gofeed.Item{Title: "Rust news"})
+	if n := strings.Count(explained.String(), "\n"); n != 2 {
+		t.Errorf("got %d explanations for two items:\n%s", n, explained.String())
+	}
+	// A new run explains it again
+	wt.Forget()
+	wt.Match(item)
+	if n := strings.Count(explained.String(), "\n"); n != 3 {
+		t.Errorf("got %d explanations after forgetting:\n%s", n, explained.String())
+	}
+}
+
+func TestWeightedTermsInvalid(t *testing.T) {
+	for _, term := range []string{"crypto", "crypto:lots", "re:(:1"} {
+		if _, err := filter.NewWeightedTerms([]string{term}, filter.DefaultFieldWeights, 0); err == nil {
+			t.Errorf("got no error from %q", term)
+		}
+	}
+	if _, err := filter.ParseFieldWeights([]string{"author=2"}); err == nil {
+		t.Errorf("got no error from an unknown field")
+	}
+}
+
+func TestModel(t *testing.T) {
+	model := filter.NewModel()
+	untrained := gofeed.Item{Title: "Anything at all"}
+	if prob := model.Prob(untrained); prob != 0.5 {
+		t.Errorf("got %g from an untrained model, want 0.5", prob)
+	}
+	for _, item := range parsedFeedFromFile("../../testdata/lobste.rs.rss").Items {
+		model.Train(*item, true)
+	}
+	for _, item := range parsedFeedFromFile("../../testdata/waxy.org.rss").Items {
+		model.Train(*item, false)
+	}
+
+	dir, err := ioutil.TempDir("", "darling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "model.json")
+	if err := model.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := filter.LoadModel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, model) {
+		t.Errorf("model changed when saved and loaded")
+	}
+
+	classifier := filter.Classifier{Model: loaded, MinProb: 0.7}
+	var tests = []struct {
+		item gofeed.Item
+		want bool
+	}{
+		{gofeed.Item{Title: "Debugging TCP sockets in systemd", Description: `<a href="https://lobste.rs/">Comments</a>`}, true},
+		{gofeed.Item{Description: "<p>Twitter and the <b>social media</b> platforms, on the web</p>"}, false},
+	}
+	for _, tt := range tests {
+		if ans := classifier.Match(tt.item); ans != tt.want {
+			t.Errorf("%q: got %t (p=%g), want %t", tt.item.Title+tt.item.Description, ans, loaded.Prob(tt.item), tt.want)
+		}
+	}
+}
+
+func TestTag(t *testing.T) {
+	golang, _ := filter.NewRegexp([]string{`re:\bGo\b|golang`})
+	inTitle, err := filter.NewField("title", golang)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := filter.Tag{Category: "go", Predicate: inTitle}
+	var tests = []struct {
+		item gofeed.Item
+		want []string
+	}{
+		{gofeed.Item{Title: "Generics in Go"}, []string{"go"}},
+		{gofeed.Item{Title: "Notes", Description: "Written in golang"}, nil},
+		{gofeed.Item{Title: "Going places"}, nil},
+	}
+	for _, tt := range tests {
+		if !tag.Match(tt.item) {
+			t.Errorf("%q: tags shouldn't drop items", tt.item.Title)
+		}
+		if got := tag.Tags(tt.item); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got tags %v, want %v", tt.item.Title, got, tt.want)
+		}
+	}
+	if _, err := filter.NewField("author", golang); err == nil {
+		t.Errorf("NewField should reject unknown fields")
+	}
+}
+
+func TestLength(t *testing.T) {
+	long := gofeed.Item{Content: "<p>" + strings.Repeat("Word after word, ", 300) + "</p>"}
+	short := gofeed.Item{Description: `<a href="https://example.com/">A link</a>`, Content: " "}
+	cjk := gofeed.Item{Description: strings.Repeat("日本語の文章", 100)}
+	var tests = []struct {
+		filter filter.Length
+		item   gofeed.Item
+		want   bool
+	}{
+		{filter.Length{MinWords: 300}, long, true},
+		{filter.Length{MinWords: 300}, short, false},
+		{filter.Length{MaxWords: 10}, short, true},
+		{filter.Length{MaxTime: 2 * time.Minute}, long, false},
+		{filter.Length{MinTime: 2 * time.Minute}, long, true},
+		{filter.Length{MinWords: 250, MaxWords: 350}, cjk, true},
+	}
+	for _, tt := range tests {
+		if ans := tt.filter.Match(tt.item); ans != tt.want {
+			t.Errorf("%+v: got %t, want %t", tt.filter, ans, tt.want)
+		}
+	}
+	if got := (&filter.Length{}).ReadingTime(long); got < 3*time.Minute || got > 4*time.Minute {
+		t.Errorf("got a reading time of %s for 900 words", got)
+	}
+}
@@ -0,0 +1,74 @@
+package filter
+
+import (
+	"github.com/mmcdole/gofeed"
+	"github.com/snark/darling/pkg/output"
+	"time"
+	"unicode"
+)
+
+// WordsPerMinute is the reading speed reading times are estimated at.
+const WordsPerMinute = 230
+
+// A ReadingTimer estimates how long the items that pass it take to read.
+type ReadingTimer interface {
+	ItemFilter
+	ReadingTime(gofeed.Item) time.Duration
+}
+
+// Length passes items by how long their text is: the text of their
+// content, or of their description if they have no content. Zero leaves
+// any of the limits unset.
+type Length struct {
+	MinWords int
+	MaxWords int
+	MinTime  time.Duration
+	MaxTime  time.Duration
+}
+
+func (filter *Length) Match(i gofeed.Item) bool {
+	words := itemWords(i)
+	reading := readingTime(words)
+	return (filter.MinWords == 0 || words >= filter.MinWords) &&
+		(filter.MaxWords == 0 || words <= filter.MaxWords) &&
+		(filter.MinTime == 0 || reading >= filter.MinTime) &&
+		(filter.MaxTime == 0 || reading <= filter.MaxTime)
+}
+
+func (filter *Length) ReadingTime(i gofeed.Item) time.Duration {
+	return readingTime(itemWords(i))
+}
+
+func readingTime(words int) time.Duration {
+	return time.Duration(words) * time.Minute / WordsPerMinute
+}
+
+func itemWords(i gofeed.Item) int {
+	text := output.PlainText(i.Content)
+	if text == "" {
+		text = output.PlainText(i.Description)
+	}
+	return countWords(text)
+}
+
+// countWords counts the words in text. Chinese and Japanese characters
+// count as half a word each, which is about how long they take to read.
+func countWords(text string) int {
+	words, cjk := 0, 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana):
+			cjk++
+			inWord = false
+		case unicode.IsSpace(r):
+			inWord = false
+		case isWordRune(r):
+			if !inWord {
+				words++
+			}
+			inWord = true
+		}
+	}
+	return words + (cjk+1)/2
+}
@@ -0,0 +1,61 @@
+package filter
+
+import (
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// normalizer puts text, and the terms to be found in it, into the same
+// form. Compatibility characters like ligatures and full-width letters
+// always become their plain equivalents (NFKC); case and accents are
+// dropped unless they matter.
+type normalizer struct {
+	caseSensitive bool
+	ignoreAccents bool
+}
+
+func (n normalizer) normalize(s string) string {
+	s = norm.NFKC.String(s)
+	if !n.caseSensitive {
+		s = strings.ToLower(s)
+	}
+	if n.ignoreAccents {
+		// Transformers keep state, so each call needs its own
+		strip := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+		if stripped, _, err := transform.String(strip, s); err == nil {
+			s = stripped
+		}
+	}
+	return s
+}
+
+// isWordRune reports whether r can be part of a word. Chinese and
+// Japanese are written without spaces between words, so their
+// characters never count, and terms next to or made of them are found
+// as if they were substrings.
+func isWordRune(r rune) bool {
+	if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) {
+		return false
+	}
+	return r == '_' || unicode.In(r, unicode.L, unicode.N, unicode.M)
+}
+
+// wholeWord reports whether text[start:end] is neither preceded nor
+// followed by a word character.
+func wholeWord(text string, start, end int) bool {
+	if start > 0 {
+		if r, _ := utf8.DecodeLastRuneInString(text[:start]); isWordRune(r) {
+			return false
+		}
+	}
+	if end < len(text) {
+		if r, _ := utf8.DecodeRuneInString(text[end:]); isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,184 @@
+package filter
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/mmcdole/gofeed"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A Scorer gives the items it matches a score, too.
+type Scorer interface {
+	ItemFilter
+	Score(gofeed.Item) float64
+}
+
+// FieldWeights multiply the score of terms found in each field.
+type FieldWeights struct {
+	Title       float64
+	Description float64
+	Content     float64
+}
+
+var DefaultFieldWeights = FieldWeights{Title: 1, Description: 1, Content: 1}
+
+// ParseFieldWeights reads multipliers given as FIELD=N, starting from
+// DefaultFieldWeights.
+func ParseFieldWeights(specs []string) (FieldWeights, error) {
+	fields := DefaultFieldWeights
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 {
+			return fields, fmt.Errorf("Field weight %q should look like title=2", spec)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return fields, fmt.Errorf("Unable to parse the weight in %q", spec)
+		}
+		switch strings.TrimSpace(parts[0]) {
+		case "title":
+			fields.Title = weight
+		case "description":
+			fields.Description = weight
+		case "content":
+			fields.Content = weight
+		default:
+			return fields, fmt.Errorf("Unknown field %q; use title, description or content", parts[0])
+		}
+	}
+	return fields, nil
+}
+
+// WeightedTerms scores items by the terms they mention, passing those
+// scoring at least Threshold. Every occurrence of a term adds its weight,
+// multiplied by that of the field it's in.
+type WeightedTerms struct {
+	Fields    FieldWeights
+	Threshold float64
+	// Explain, if set, is written a line about each item scored, the
+	// first time it's matched.
+	Explain io.Writer
+	terms   *termSet
+	names   []string
+	weights []float64
+	// scored remembers each item's score, since an item is matched and
+	// then scored, and may be matched again for each route
+	mu     sync.Mutex
+	scored map[[sha256.Size]byte]*scoredItem
+}
+
+type scoredItem struct {
+	score     float64
+	reasons   []string
+	explained bool
+}
+
+// NewWeightedTerms builds a scoring filter from terms given as TERM:N,
+// such as "crypto:-2" or "rust:+3". Terms can use any of the modes
+// described by term.
+func NewWeightedTerms(weighted []string, fields FieldWeights, threshold float64) (*WeightedTerms, error) {
+	filter := &WeightedTerms{Fields: fields, Threshold: threshold}
+	words := []string{}
+	for _, spec := range weighted {
+		spec = strings.TrimSpace(spec)
+		i := strings.LastIndex(spec, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("Term %q has no weight; give one like %q", spec, spec+":-1")
+		}
+		weight, err := strconv.ParseFloat(spec[i+1:], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse the weight of %q", spec)
+		}
+		words = append(words, spec[:i])
+		filter.names = append(filter.names, spec[:i])
+		filter.weights = append(filter.weights, weight)
+	}
+	terms, err := compileTerms(words)
+	if err != nil {
+		return nil, err
+	}
+	filter.terms = terms
+	return filter, nil
+}
+
+func (filter *WeightedTerms) Match(i gofeed.Item) bool {
+	scored := filter.lookup(i)
+	pass := scored.score >= filter.Threshold
+	if filter.Explain != nil {
+		filter.mu.Lock()
+		explain := !scored.explained
+		scored.explained = true
+		filter.mu.Unlock()
+		if explain {
+			verdict := "dropped"
+			if pass {
+				verdict = "kept"
+			}
+			fmt.Fprintf(filter.Explain, "%g\t%s\t%s\t%s\n", scored.score, verdict, i.Title, strings.Join(scored.reasons, ", "))
+		}
+	}
+	return pass
+}
+
+func (filter *WeightedTerms) Score(i gofeed.Item) float64 {
+	return filter.lookup(i).score
+}
+
+// Forget clears the scores remembered for items, so that a long-running
+// process only keeps those for the items in its latest run.
+func (filter *WeightedTerms) Forget() {
+	filter.mu.Lock()
+	filter.scored = nil
+	filter.mu.Unlock()
+}
+
+// lookup scores the item, unless it's been scored before.
+func (filter *WeightedTerms) lookup(i gofeed.Item) *scoredItem {
+	key := sha256.Sum256([]byte(i.Title + "\x00" + i.Description + "\x00" + i.Content))
+	filter.mu.Lock()
+	scored, found := filter.scored[key]
+	filter.mu.Unlock()
+	if found {
+		return scored
+	}
+	score, reasons := filter.score(i)
+	scored = &scoredItem{score: score, reasons: reasons}
+	filter.mu.Lock()
+	if filter.scored == nil {
+		filter.scored = map[[sha256.Size]byte]*scoredItem{}
+	}
+	filter.scored[key] = scored
+	filter.mu.Unlock()
+	return scored
+}
+
+// score adds up the item's score, describing where it came from.
+func (filter *WeightedTerms) score(i gofeed.Item) (float64, []string) {
+	score := 0.0
+	reasons := []string{}
+	fields := []struct {
+		name   string
+		text   string
+		weight float64
+	}{
+		{"title", i.Title, filter.Fields.Title},
+		{"description", i.Description, filter.Fields.Description},
+		{"content", i.Content, filter.Fields.Content},
+	}
+	for _, field := range fields {
+		if field.weight == 0 || field.text == "" {
+			continue
+		}
+		for index, n := range filter.terms.count(field.text) {
+			if n == 0 {
+				continue
+			}
+			score += float64(n) * filter.weights[index] * field.weight
+			reasons = append(reasons, fmt.Sprintf("%s %+g×%d in %s", filter.names[index], filter.weights[index], n, field.name))
+		}
+	}
+	return score, reasons
+}
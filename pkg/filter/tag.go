@@ -0,0 +1,61 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/mmcdole/gofeed"
+)
+
+// A Tagger gives the items that pass it categories, too.
+type Tagger interface {
+	ItemFilter
+	Tags(gofeed.Item) []string
+}
+
+// Tag gives the items Predicate matches a category. It passes every
+// item, so it can sit among the filters without dropping anything.
+type Tag struct {
+	Category  string
+	Predicate ItemFilter
+	// Rule describes what Predicate matches, to tell tags apart
+	Rule string
+}
+
+func (filter *Tag) Match(i gofeed.Item) bool {
+	return true
+}
+
+func (filter *Tag) Tags(i gofeed.Item) []string {
+	if filter.Predicate.Match(i) {
+		return []string{filter.Category}
+	}
+	return nil
+}
+
+// Field matches items by a single field: Base sees an item with nothing
+// else in it.
+type Field struct {
+	// Name is 'title', 'description' or 'content'.
+	Name string
+	Base ItemFilter
+}
+
+func NewField(name string, base ItemFilter) (*Field, error) {
+	switch name {
+	case "title", "description", "content":
+		return &Field{Name: name, Base: base}, nil
+	}
+	return nil, fmt.Errorf("Unknown field %q; use title, description or content", name)
+}
+
+func (filter *Field) Match(i gofeed.Item) bool {
+	var only gofeed.Item
+	switch filter.Name {
+	case "title":
+		only.Title = i.Title
+	case "description":
+		only.Description = i.Description
+	case "content":
+		only.Content = i.Content
+	}
+	return filter.Base.Match(only)
+}
@@ -0,0 +1,242 @@
+package filter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ReadTerms reads a list of terms, one per line, for NewRegexp. Blank
+// lines are skipped, as is anything after a '#' at the start of a line
+// or following whitespace, so a term like "c#" survives.
+func ReadTerms(r io.Reader) ([]string, error) {
+	terms := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		if i := strings.Index(line, "\t#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line != "" {
+			terms = append(terms, line)
+		}
+	}
+	return terms, scanner.Err()
+}
+
+// A term is a single entry of a word list, parsed from its prefixes:
+//
+//	word        the word or phrase, as a whole word, ignoring case
+//	sub:text    the text anywhere, even inside a word
+//	glob:pat*   whole words matching a glob; '*' stands for any run of
+//	            non-space characters and '?' for any one
+//	re:expr     a regular expression, found anywhere
+//	cs:...      any of the above, matching case exactly
+//	ai:...      any of the above, ignoring accents, so "cafe" finds "café"
+//
+// Nothing in a plain word is special, so "c++" means just that.
+type term struct {
+	normalizer
+	source string
+	text   string
+	mode   string
+}
+
+const (
+	literalMode   = "literal"
+	substringMode = "sub"
+	globMode      = "glob"
+	regexpMode    = "re"
+)
+
+func parseTerm(s string) term {
+	t := term{source: s, text: s, mode: literalMode}
+	for {
+		if strings.HasPrefix(t.text, "cs:") {
+			t.text = strings.TrimPrefix(t.text, "cs:")
+			t.caseSensitive = true
+		} else if strings.HasPrefix(t.text, "ai:") {
+			t.text = strings.TrimPrefix(t.text, "ai:")
+			t.ignoreAccents = true
+		} else {
+			break
+		}
+	}
+	for _, mode := range []string{substringMode, globMode, regexpMode} {
+		if strings.HasPrefix(t.text, mode+":") {
+			t.text = strings.TrimPrefix(t.text, mode+":")
+			t.mode = mode
+			break
+		}
+	}
+	if t.mode != regexpMode {
+		t.text = t.normalize(t.text)
+	}
+	return t
+}
+
+// pattern is the term as regexp syntax, for globs and regexps. Globs
+// still need to be checked for whole words.
+func (t term) pattern() (string, error) {
+	expr := t.text
+	if t.mode == globMode {
+		var b strings.Builder
+		for _, r := range t.text {
+			switch r {
+			case '*':
+				b.WriteString(`[^\s\p{Z}]*`)
+			case '?':
+				b.WriteString(`[^\s\p{Z}]`)
+			default:
+				b.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+		expr = b.String()
+	}
+	if _, err := regexp.Compile(expr); err != nil {
+		return "", fmt.Errorf("Invalid term %q: %s", t.source, err)
+	}
+	if t.caseSensitive {
+		return "(?:" + expr + ")", nil
+	}
+	return "(?i:" + expr + ")", nil
+}
+
+// termSet is a compiled list of terms. Terms are grouped by how the text
+// needs to be normalized for them; in each group, words, phrases and
+// substrings are all found in one pass by an automaton, and regexps are
+// combined into one.
+type termSet struct {
+	groups []*termGroup
+	size   int
+}
+
+type termGroup struct {
+	normalizer
+	words *automaton
+	// combined is every regexp term at once, for matching; patterns
+	// holds each regexp and glob term, for globs and counting.
+	combined *regexp.Regexp
+	patterns []indexedPattern
+}
+
+type indexedTerm struct {
+	term
+	index int
+}
+
+type indexedPattern struct {
+	*regexp.Regexp
+	index     int
+	wholeWord bool
+}
+
+// compileTerms compiles a list of terms, skipping any which are blank.
+// Terms are numbered by their position in words.
+func compileTerms(words []string) (*termSet, error) {
+	set := &termSet{size: len(words)}
+	groups := map[normalizer]*termGroup{}
+	literals := map[normalizer][]indexedTerm{}
+	combined := map[normalizer][]string{}
+	for i, word := range words {
+		word = strings.TrimSpace(word)
+		// Silently discard empty/whitespace strings
+		if word == "" {
+			continue
+		}
+		t := parseTerm(word)
+		if t.text == "" {
+			return nil, fmt.Errorf("Empty term %q", word)
+		}
+		group := groups[t.normalizer]
+		if group == nil {
+			group = &termGroup{normalizer: t.normalizer}
+			groups[t.normalizer] = group
+			set.groups = append(set.groups, group)
+		}
+		if t.mode == literalMode || t.mode == substringMode {
+			literals[t.normalizer] = append(literals[t.normalizer], indexedTerm{t, i})
+			continue
+		}
+		pattern, err := t.pattern()
+		if err != nil {
+			return nil, err
+		}
+		group.patterns = append(group.patterns, indexedPattern{regexp.MustCompile(pattern), i, t.mode == globMode})
+		if t.mode == regexpMode {
+			combined[t.normalizer] = append(combined[t.normalizer], pattern)
+		}
+	}
+	for n, group := range groups {
+		if len(literals[n]) > 0 {
+			group.words = newAutomaton(literals[n])
+		}
+		if len(combined[n]) > 0 {
+			// Each pattern compiles alone, so together they must too
+			group.combined = regexp.MustCompile(strings.Join(combined[n], "|"))
+		}
+	}
+	return set, nil
+}
+
+// match reports whether any term occurs in text.
+func (set *termSet) match(text string) bool {
+	for _, group := range set.groups {
+		normalized := group.normalize(text)
+		if group.words != nil && group.words.MatchString(normalized) {
+			return true
+		}
+		if group.combined != nil && group.combined.MatchString(normalized) {
+			return true
+		}
+		for _, p := range group.patterns {
+			if p.wholeWord && p.count(normalized, true) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// count counts the occurrences of each term in text, by its index.
+func (set *termSet) count(text string) []int {
+	counts := make([]int, set.size)
+	for _, group := range set.groups {
+		normalized := group.normalize(text)
+		if group.words != nil {
+			group.words.each(normalized, func(index int) bool {
+				counts[index]++
+				return true
+			})
+		}
+		for _, p := range group.patterns {
+			counts[p.index] += p.count(normalized, false)
+		}
+	}
+	return counts
+}
+
+// count counts the pattern's occurrences in text, or just finds the
+// first if that's all that's wanted.
+func (p indexedPattern) count(text string, first bool) int {
+	n := 0
+	for _, loc := range p.FindAllStringIndex(text, -1) {
+		if p.wholeWord && !wholeWord(text, loc[0], loc[1]) {
+			continue
+		}
+		n++
+		if first {
+			break
+		}
+	}
+	return n
+}
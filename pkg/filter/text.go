@@ -0,0 +1,46 @@
+package filter
+
+import (
+	"unicode"
+)
+
+// tokenize splits text into normalized words. Chinese and Japanese
+// aren't written with spaces between words, so runs of their characters
+// are split into overlapping pairs instead.
+func tokenize(text string) []string {
+	text = normalizer{}.normalize(text)
+	tokens := []string{}
+	word := []rune{}
+	cjk := []rune{}
+	flush := func() {
+		if len(word) > 1 {
+			tokens = append(tokens, string(word))
+		}
+		if len(cjk) == 1 {
+			tokens = append(tokens, string(cjk))
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			tokens = append(tokens, string(cjk[i:i+2]))
+		}
+		word = word[:0]
+		cjk = cjk[:0]
+	}
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana):
+			if len(word) > 0 {
+				flush()
+			}
+			cjk = append(cjk, r)
+		case isWordRune(r):
+			if len(cjk) > 0 {
+				flush()
+			}
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
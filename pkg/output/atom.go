@@ -0,0 +1,144 @@
+package output
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/gorilla/feeds"
+	"net/url"
+	"time"
+)
+
+// These mirror gorilla's Atom types, adding what gorilla can't express.
+
+type atomFeed struct {
+	XMLName  xml.Name `xml:"feed"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Darling  string   `xml:"xmlns:darling,attr,omitempty"`
+	Lang     string   `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Title    string   `xml:"title"`   // required
+	Id       string   `xml:"id"`      // required
+	Updated  string   `xml:"updated"` // required
+	Icon     string   `xml:"icon,omitempty"`
+	Logo     string   `xml:"logo,omitempty"`
+	Rights   string   `xml:"rights,omitempty"` // copyright used
+	Subtitle string   `xml:"subtitle,omitempty"`
+	Links    []feeds.AtomLink
+	Author   *feeds.AtomAuthor `xml:"author,omitempty"`
+	Entries  []*atomEntry      `xml:"entry"`
+}
+
+type atomEntry struct {
+	XMLName   xml.Name `xml:"entry"`
+	Title     string   `xml:"title"`   // required
+	Updated   string   `xml:"updated"` // required
+	Id        string   `xml:"id"`      // required
+	Content   *feeds.AtomContent
+	Published string             `xml:"published,omitempty"`
+	Links     []feeds.AtomLink   // required if no child 'content' elements
+	Summary   *feeds.AtomSummary // required if content has src or content is base64
+	Author    *feeds.AtomAuthor  // required if feed lacks an author
+	Category  []atomCategory
+	Source    *atomSource
+	// ReadingTime is in minutes
+	ReadingTime int `xml:"darling:readingTime,omitempty"`
+}
+
+type atomCategory struct {
+	XMLName xml.Name `xml:"category"`
+	Term    string   `xml:"term,attr"`
+}
+
+// atomSource is the <source> of an entry copied from another feed
+type atomSource struct {
+	XMLName xml.Name         `xml:"source"`
+	Id      string           `xml:"id,omitempty"`
+	Title   string           `xml:"title,omitempty"`
+	Links   []feeds.AtomLink // alternate and self
+}
+
+func newAtomEntry(i *Item) *atomEntry {
+	id := i.Id
+	// Atom requires an id; make one up the way gorilla does
+	if len(id) == 0 {
+		if len(i.Link.Href) > 0 && (!i.Created.IsZero() || !i.Updated.IsZero()) {
+			dateStr := anyTimeFormat("2006-01-02", i.Updated, i.Created)
+			host, path := i.Link.Href, "/invalid.html"
+			if u, err := url.Parse(i.Link.Href); err == nil {
+				host, path = u.Host, u.Path
+			}
+			id = fmt.Sprintf("tag:%s,%s:%s", host, dateStr, path)
+		} else {
+			id = "urn:uuid:" + feeds.NewUUID().String()
+		}
+	}
+	linkRel := i.Link.Rel
+	if linkRel == "" {
+		linkRel = "alternate"
+	}
+	entry := &atomEntry{
+		Title:   i.Title,
+		Links:   []feeds.AtomLink{{Href: i.Link.Href, Rel: linkRel, Type: i.Link.Type}},
+		Id:      id,
+		Updated: anyTimeFormat(time.RFC3339, i.Updated, i.Created),
+		Summary: &feeds.AtomSummary{Content: i.Description, Type: "html"},
+	}
+	entry.ReadingTime = readingMinutes(i.ReadingTime)
+	if len(i.Content) > 0 {
+		entry.Content = &feeds.AtomContent{Content: i.Content, Type: "html"}
+	}
+	if i.Enclosure != nil && linkRel != "enclosure" {
+		entry.Links = append(entry.Links, feeds.AtomLink{Href: i.Enclosure.Url, Rel: "enclosure", Type: i.Enclosure.Type, Length: i.Enclosure.Length})
+	}
+	if i.Author != nil && (len(i.Author.Name) > 0 || len(i.Author.Email) > 0) {
+		entry.Author = &feeds.AtomAuthor{AtomPerson: feeds.AtomPerson{Name: i.Author.Name, Email: i.Author.Email}}
+	}
+	for _, category := range i.Categories {
+		entry.Category = append(entry.Category, atomCategory{Term: category})
+	}
+	if i.Source != nil {
+		source := &atomSource{Id: i.Source.ID, Title: i.Source.Title}
+		if i.Source.Link != "" {
+			source.Links = append(source.Links, feeds.AtomLink{Href: i.Source.Link, Rel: "alternate"})
+		}
+		if i.Source.URL != "" {
+			source.Links = append(source.Links, feeds.AtomLink{Href: i.Source.URL, Rel: "self"})
+		}
+		if source.Id != "" || source.Title != "" || len(source.Links) > 0 {
+			entry.Source = source
+		}
+	}
+	return entry
+}
+
+func FeedToAtom(outfeed *Feed) (string, error) {
+	doc := &atomFeed{
+		Xmlns:    "http://www.w3.org/2005/Atom",
+		Lang:     outfeed.Language,
+		Title:    outfeed.Title,
+		Links:    []feeds.AtomLink{{Href: outfeed.Link.Href, Rel: outfeed.Link.Rel}},
+		Subtitle: outfeed.Description,
+		Id:       outfeed.Link.Href,
+		Updated:  anyTimeFormat(time.RFC3339, outfeed.Updated, outfeed.Created),
+		Icon:     outfeed.Icon,
+		Rights:   outfeed.Copyright,
+	}
+	if outfeed.Self != "" {
+		// A feed's own URL makes a better id than its site's
+		doc.Id = outfeed.Self
+		doc.Links = append(doc.Links, feeds.AtomLink{Href: outfeed.Self, Rel: "self", Type: "application/atom+xml"})
+	}
+	if outfeed.Image != nil {
+		doc.Logo = outfeed.Image.Url
+	}
+	if outfeed.Author != nil {
+		doc.Author = &feeds.AtomAuthor{AtomPerson: feeds.AtomPerson{Name: outfeed.Author.Name, Email: outfeed.Author.Email}}
+	}
+	for _, i := range outfeed.Items {
+		entry := newAtomEntry(i)
+		if entry.ReadingTime > 0 {
+			doc.Darling = Namespace
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return toXML(doc)
+}
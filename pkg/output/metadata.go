@@ -0,0 +1,137 @@
+package output
+
+import (
+	"bytes"
+	"github.com/gorilla/feeds"
+	"github.com/mmcdole/gofeed"
+	"net/mail"
+	"text/template"
+)
+
+// Metadata describes the output feed itself. Every field is a
+// text/template, executed against the feeds being combined: .Source is
+// the first of them and .Sources all of them, as parsed by gofeed. So a
+// title of "Filtered: {{.Source.Title}}" names the feed being filtered.
+type Metadata struct {
+	Title       string `json:"title,omitempty"`
+	Link        string `json:"link,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Author is a name, optionally followed by an <email address>
+	Author    string `json:"author,omitempty"`
+	Language  string `json:"language,omitempty"`
+	Image     string `json:"image,omitempty"`
+	Icon      string `json:"icon,omitempty"`
+	Copyright string `json:"copyright,omitempty"`
+	// Self is the URL the output feed will be published at
+	Self string `json:"self,omitempty"`
+}
+
+// Merge returns m, with any fields that are set in override replaced.
+func (m Metadata) Merge(override Metadata) Metadata {
+	merged := m
+	for _, field := range []struct {
+		dst *string
+		src string
+	}{
+		{&merged.Title, override.Title},
+		{&merged.Link, override.Link},
+		{&merged.Description, override.Description},
+		{&merged.Author, override.Author},
+		{&merged.Language, override.Language},
+		{&merged.Image, override.Image},
+		{&merged.Icon, override.Icon},
+		{&merged.Copyright, override.Copyright},
+		{&merged.Self, override.Self},
+	} {
+		if field.src != "" {
+			*field.dst = field.src
+		}
+	}
+	return merged
+}
+
+type metadataSources struct {
+	Source  *gofeed.Feed
+	Sources []*gofeed.Feed
+}
+
+// Apply sets outfeed's metadata from m, expanding each field's template
+// against sources. Fields left empty keep outfeed's defaults.
+func (m Metadata) Apply(outfeed *Feed, sources []*gofeed.Feed) error {
+	data := metadataSources{Source: &gofeed.Feed{}, Sources: sources}
+	if len(sources) > 0 {
+		data.Source = sources[0]
+	}
+	expand := func(name string, text string) (string, error) {
+		tmpl, err := template.New(name).Parse(text)
+		if err != nil {
+			return "", err
+		}
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, data); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+
+	fields := []struct {
+		name  string
+		text  string
+		apply func(string)
+	}{
+		{"title", m.Title, func(s string) { outfeed.Title = s }},
+		{"link", m.Link, func(s string) { outfeed.Link = &feeds.Link{Href: s} }},
+		{"description", m.Description, func(s string) { outfeed.Description = s }},
+		{"author", m.Author, func(s string) { outfeed.Author = parseAuthor(s) }},
+		{"language", m.Language, func(s string) { outfeed.Language = s }},
+		{"image", m.Image, func(s string) {
+			outfeed.Image = &feeds.Image{Url: s, Title: outfeed.Title, Link: outfeed.Link.Href}
+		}},
+		{"icon", m.Icon, func(s string) { outfeed.Icon = s }},
+		{"copyright", m.Copyright, func(s string) { outfeed.Copyright = s }},
+		{"self", m.Self, func(s string) { outfeed.Self = s }},
+	}
+	for _, field := range fields {
+		if field.text == "" {
+			continue
+		}
+		value, err := expand(field.name, field.text)
+		if err != nil {
+			return err
+		}
+		field.apply(value)
+	}
+	return nil
+}
+
+// Check parses each field's template, to catch mistakes before there's
+// anything to apply them to.
+func (m Metadata) Check() error {
+	for _, field := range []struct {
+		name string
+		text string
+	}{
+		{"title", m.Title},
+		{"link", m.Link},
+		{"description", m.Description},
+		{"author", m.Author},
+		{"language", m.Language},
+		{"image", m.Image},
+		{"icon", m.Icon},
+		{"copyright", m.Copyright},
+		{"self", m.Self},
+	} {
+		if _, err := template.New(field.name).Parse(field.text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseAuthor accepts either a bare name or "Name <email>".
+func parseAuthor(s string) *feeds.Author {
+	if address, err := mail.ParseAddress(s); err == nil {
+		return &feeds.Author{Name: address.Name, Email: address.Address}
+	}
+	return &feeds.Author{Name: s}
+}
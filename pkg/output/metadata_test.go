@@ -0,0 +1,67 @@
+package output_test
+
+import (
+	"github.com/gorilla/feeds"
+	"github.com/mmcdole/gofeed"
+	"github.com/snark/darling/pkg/output"
+	"strings"
+	"testing"
+)
+
+func TestMetadataMerge(t *testing.T) {
+	base := output.Metadata{Title: "Base", Language: "en"}
+	merged := base.Merge(output.Metadata{Title: "Override"})
+	if merged.Title != "Override" {
+		t.Errorf("got title %s, want Override", merged.Title)
+	}
+	if merged.Language != "en" {
+		t.Errorf("got language %s, want en", merged.Language)
+	}
+}
+
+func TestMetadataApply(t *testing.T) {
+	outfeed := &output.Feed{
+		Feed: feeds.Feed{
+			Title:  "Darling",
+			Link:   &feeds.Link{Href: ""},
+			Author: &feeds.Author{Name: "You"},
+		},
+	}
+	sources := []*gofeed.Feed{{Title: "Lobsters"}, {Title: "Tilde News"}}
+	m := output.Metadata{
+		Title:  "Filtered: {{.Source.Title}}",
+		Author: "Jane Doe <jane@example.com>",
+		Self:   "https://example.com/feed.xml",
+	}
+	if err := m.Apply(outfeed, sources); err != nil {
+		t.Fatal(err)
+	}
+	if outfeed.Title != "Filtered: Lobsters" {
+		t.Errorf("got title %s", outfeed.Title)
+	}
+	if outfeed.Author.Name != "Jane Doe" || outfeed.Author.Email != "jane@example.com" {
+		t.Errorf("got author %v", outfeed.Author)
+	}
+	rss, _ := output.FeedToRss(outfeed)
+	if !strings.Contains(rss, `<atom:link href="https://example.com/feed.xml" rel="self" type="application/rss+xml">`) {
+		t.Errorf("RSS output is missing its self link")
+	}
+	atom, _ := output.FeedToAtom(outfeed)
+	if !strings.Contains(atom, `<link href="https://example.com/feed.xml" rel="self" type="application/atom+xml">`) {
+		t.Errorf("Atom output is missing its self link")
+	}
+}
+
+func TestMetadataApplyBadTemplate(t *testing.T) {
+	outfeed := &output.Feed{Feed: feeds.Feed{Link: &feeds.Link{}}}
+	m := output.Metadata{Title: "{{.Source.Title"}
+	if err := m.Apply(outfeed, nil); err == nil {
+		t.Errorf("did not return an error for an unparseable template")
+	}
+	if err := m.Check(); err == nil {
+		t.Errorf("Check did not return an error for an unparseable template")
+	}
+	if err := (output.Metadata{Title: "Filtered: {{.Source.Title}}"}).Check(); err != nil {
+		t.Errorf("Check rejected a good template: %s", err)
+	}
+}
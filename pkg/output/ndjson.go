@@ -0,0 +1,80 @@
+package output
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// JSONItem is how an item is written as NDJSON, one per line. It's also
+// what the NDJSON input reads back, so that jq can sit between two runs
+// of darling.
+type JSONItem struct {
+	ID          string     `json:"id,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	Link        string     `json:"link,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Content     string     `json:"content,omitempty"`
+	Published   *time.Time `json:"published,omitempty"`
+	Updated     *time.Time `json:"updated,omitempty"`
+	Source      *Source    `json:"source,omitempty"`
+	Score       *float64   `json:"score,omitempty"`
+	Categories  []string   `json:"categories,omitempty"`
+	// ReadingTime is in minutes
+	ReadingTime int `json:"readingTime,omitempty"`
+	// Enclosure is a file that comes with the item, like a podcast's
+	// episode
+	Enclosure *JSONEnclosure `json:"enclosure,omitempty"`
+}
+
+// JSONEnclosure is an item's enclosure, as written in NDJSON.
+type JSONEnclosure struct {
+	URL    string `json:"url"`
+	Type   string `json:"type,omitempty"`
+	Length string `json:"length,omitempty"`
+}
+
+// NewJSONItem converts an item for NDJSON output.
+func NewJSONItem(item *Item) *JSONItem {
+	j := &JSONItem{
+		ID:          item.Id,
+		Title:       item.Title,
+		Description: item.Description,
+		Content:     item.Content,
+		Source:      item.Source,
+		Score:       item.Score,
+		Categories:  item.Categories,
+		ReadingTime: readingMinutes(item.ReadingTime),
+	}
+	if item.Link != nil {
+		j.Link = item.Link.Href
+	}
+	if item.Enclosure != nil {
+		j.Enclosure = &JSONEnclosure{URL: item.Enclosure.Url, Type: item.Enclosure.Type, Length: item.Enclosure.Length}
+	}
+	if !item.Created.IsZero() {
+		published := item.Created
+		j.Published = &published
+	}
+	if !item.Updated.IsZero() {
+		updated := item.Updated
+		j.Updated = &updated
+	}
+	return j
+}
+
+// FeedToNDJSON writes each item as a JSON object on its own line. The
+// feed's own metadata isn't included.
+func FeedToNDJSON(outfeed *Feed) (string, error) {
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	// Item content is mostly HTML; keep it readable
+	encoder.SetEscapeHTML(false)
+	for _, item := range outfeed.Items {
+		if err := encoder.Encode(NewJSONItem(item)); err != nil {
+			return "", err
+		}
+	}
+	// Callers print the result with a trailing newline of their own
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
@@ -1,15 +1,149 @@
 package output
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
 	"github.com/gorilla/feeds"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
 )
 
-// TODO: Handle errors
+// Feed is the feed darling builds. It's gorilla's feed, with a few more
+// fields and darling's items in place of gorilla's own.
+type Feed struct {
+	feeds.Feed
+	Language string
+	// Icon is a small square image, where Image is a larger logo
+	Icon string
+	// Self is the URL the feed itself is published at
+	Self  string
+	Items []*Item
+}
+
+// Item is an output item, along with what darling knows about where it
+// came from. Its Source replaces gorilla's link-only one.
+type Item struct {
+	*feeds.Item
+	Source *Source
+	// Score is what a scoring filter made of the item, if one was used
+	Score *float64
+	// Categories are the item's own, and any it was tagged with
+	Categories []string
+	// ReadingTime is how long the item should take to read, if that was
+	// estimated
+	ReadingTime time.Duration
+	// DatedNow is set if the item had no date of its own, and was dated at
+	// the time of the run
+	DatedNow bool
+}
+
+// Namespace is that of darling's own extensions to RSS and Atom.
+const Namespace = "https://github.com/snark/darling"
+
+// Source describes the feed an item was found in.
+type Source struct {
+	// URL is where the feed was fetched from, when known
+	URL   string `json:"url,omitempty"`
+	Title string `json:"title,omitempty"`
+	// Link is the website the feed belongs to
+	Link string `json:"link,omitempty"`
+	// ID is the feed's Atom id, if it had one
+	ID string `json:"id,omitempty"`
+}
+
+// anyTimeFormat formats the first non-zero time, as gorilla does.
+func anyTimeFormat(format string, times ...time.Time) string {
+	for _, t := range times {
+		if !t.IsZero() {
+			return t.Format(format)
+		}
+	}
+	return ""
+}
+
+// readingMinutes is a reading time in whole minutes, rounded up. Items
+// without one have none.
+func readingMinutes(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Minute - 1) / time.Minute)
+}
+
+// toXML renders one of darling's XML documents, with the same header and
+// indentation as gorilla.
+func toXML(doc interface{}) (string, error) {
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	// strip empty line from default xml header
+	return xml.Header[:len(xml.Header)-1] + string(data), nil
+}
 
-func FeedToAtom(outfeed *feeds.Feed) (string, error) {
-	return outfeed.ToAtom()
+// Fingerprint digests the feed's contents. The feed's own build times,
+// and the dates of items dated at the time of the run, are left out, so
+// two runs which find the same items agree.
+func Fingerprint(outfeed *Feed) (string, error) {
+	stable := *outfeed
+	stable.Created = time.Time{}
+	stable.Updated = time.Time{}
+	stable.Items = make([]*Item, len(outfeed.Items))
+	for i, item := range outfeed.Items {
+		stable.Items[i] = item
+		if item.DatedNow {
+			undated := *item.Item
+			undated.Created = time.Time{}
+			undated.Updated = time.Time{}
+			copied := *item
+			copied.Item = &undated
+			stable.Items[i] = &copied
+		}
+	}
+	// RSS covers the feed itself, NDJSON everything about its items
+	rss, err := FeedToRss(&stable)
+	if err != nil {
+		return "", err
+	}
+	ndjson, err := FeedToNDJSON(&stable)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(rss + ndjson))
+	return hex.EncodeToString(sum[:]), nil
 }
 
-func FeedToRss(outfeed *feeds.Feed) (string, error) {
-	return outfeed.ToRss()
+// WriteFile replaces the file at path with content. The content is
+// written to a temporary file in the same directory and renamed into
+// place, so readers see either the old file or the new one, never a
+// partial write.
+func WriteFile(path string, content string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	// Cleanup is a no-op once the rename has succeeded.
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
@@ -0,0 +1,186 @@
+package output_test
+
+import (
+	"github.com/gorilla/feeds"
+	"github.com/snark/darling/pkg/output"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sourcedFeed() *output.Feed {
+	return &output.Feed{
+		Feed: feeds.Feed{
+			Title: "Darling",
+			Link:  &feeds.Link{Href: ""},
+		},
+		Items: []*output.Item{{
+			Item: &feeds.Item{
+				Title:   "An item",
+				Link:    &feeds.Link{Href: "https://example.com/item"},
+				Created: time.Date(2019, 10, 12, 16, 25, 0, 0, time.UTC),
+			},
+			Source: &output.Source{
+				URL:   "https://example.com/feed.atom",
+				Title: "Example",
+				Link:  "https://example.com/",
+				ID:    "tag:example.com,2019:feed",
+			},
+		}},
+	}
+}
+
+func TestSourceAttribution(t *testing.T) {
+	outfeed := sourcedFeed()
+	rss, err := output.FeedToRss(outfeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rss, `<source url="https://example.com/feed.atom">Example</source>`) {
+		t.Errorf("RSS output is missing the item's source")
+	}
+	atom, err := output.FeedToAtom(outfeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<id>tag:example.com,2019:feed</id>",
+		"<title>Example</title>",
+		`<link href="https://example.com/feed.atom" rel="self"></link>`,
+	} {
+		if !strings.Contains(atom, want) {
+			t.Errorf("Atom output is missing %s from the entry's source", want)
+		}
+	}
+	ndjson, err := output.FeedToNDJSON(outfeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(ndjson, `"source":{"url":"https://example.com/feed.atom","title":"Example"`) {
+		t.Errorf("NDJSON output is missing the item's source: %s", ndjson)
+	}
+}
+
+func TestCategories(t *testing.T) {
+	outfeed := sourcedFeed()
+	outfeed.Items[0].Categories = []string{"go", "databases"}
+	rss, err := output.FeedToRss(outfeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rss, "<category>go</category>") || !strings.Contains(rss, "<category>databases</category>") {
+		t.Errorf("RSS output is missing the item's categories")
+	}
+	atom, err := output.FeedToAtom(outfeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(atom, `<category term="go"></category>`) {
+		t.Errorf("Atom output is missing the item's categories")
+	}
+	ndjson, err := output.FeedToNDJSON(outfeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(ndjson, `"categories":["go","databases"]`) {
+		t.Errorf("NDJSON output is missing the item's categories: %s", ndjson)
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	outfeed := sourcedFeed()
+	outfeed.Items[0].ReadingTime = 150 * time.Second
+	rss, err := output.FeedToRss(outfeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rss, `xmlns:darling="https://github.com/snark/darling"`) || !strings.Contains(rss, "<darling:readingTime>3</darling:readingTime>") {
+		t.Errorf("RSS output is missing the item's reading time")
+	}
+	ndjson, err := output.FeedToNDJSON(outfeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(ndjson, `"readingTime":3`) {
+		t.Errorf("NDJSON output is missing the item's reading time: %s", ndjson)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	fingerprint := func(outfeed *output.Feed) string {
+		sum, err := output.Fingerprint(outfeed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sum
+	}
+	first := sourcedFeed()
+	first.Created = time.Now()
+	want := fingerprint(first)
+
+	// Build times, and the dates given to undated items, don't count
+	rebuilt := sourcedFeed()
+	rebuilt.Created = time.Now().Add(time.Hour)
+	if got := fingerprint(rebuilt); got != want {
+		t.Errorf("fingerprint changed with the build time")
+	}
+	undated := func(created time.Time) *output.Feed {
+		outfeed := sourcedFeed()
+		outfeed.Items[0].Created = created
+		outfeed.Items[0].DatedNow = true
+		return outfeed
+	}
+	dated := undated(time.Now())
+	if fingerprint(dated) != fingerprint(undated(time.Now().Add(time.Hour))) {
+		t.Errorf("fingerprint changed with the date given to an undated item")
+	}
+	if dated.Items[0].Created.IsZero() {
+		t.Errorf("fingerprinting changed the item's date")
+	}
+
+	changed := sourcedFeed()
+	changed.Items[0].Created = changed.Items[0].Created.Add(time.Hour)
+	if got := fingerprint(changed); got == want {
+		t.Errorf("fingerprint didn't change with an item's own date")
+	}
+	changed = sourcedFeed()
+	changed.Items[0].Title = "Another item"
+	if got := fingerprint(changed); got == want {
+		t.Errorf("fingerprint didn't change with an item's title")
+	}
+}
+
+func TestRssGuid(t *testing.T) {
+	cases := []struct {
+		id   string
+		want string
+	}{
+		{"https://example.com/item", `<guid>https://example.com/item</guid>`},
+		{"https://example.com/item?id=1", `<guid isPermaLink="false">https://example.com/item?id=1</guid>`},
+		{"urn:darling:0123", `<guid isPermaLink="false">urn:darling:0123</guid>`},
+		{"tag:example.com,2019:1", `<guid isPermaLink="false">tag:example.com,2019:1</guid>`},
+	}
+	for _, c := range cases {
+		outfeed := sourcedFeed()
+		outfeed.Items[0].Id = c.id
+		rss, err := output.FeedToRss(outfeed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(rss, c.want) {
+			t.Errorf("RSS output is missing %s", c.want)
+		}
+	}
+}
+
+func TestNDJSONEnclosure(t *testing.T) {
+	outfeed := sourcedFeed()
+	outfeed.Items[0].Enclosure = &feeds.Enclosure{Url: "https://example.com/episode.mp3", Type: "audio/mpeg", Length: "1234"}
+	ndjson, err := output.FeedToNDJSON(outfeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(ndjson, `"enclosure":{"url":"https://example.com/episode.mp3","type":"audio/mpeg","length":"1234"}`) {
+		t.Errorf("NDJSON output is missing the item's enclosure: %s", ndjson)
+	}
+}
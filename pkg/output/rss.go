@@ -0,0 +1,150 @@
+package output
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/gorilla/feeds"
+	"strings"
+	"time"
+)
+
+// These mirror gorilla's RSS types, adding what gorilla can't express.
+
+type rssFeedXml struct {
+	XMLName          xml.Name `xml:"rss"`
+	Version          string   `xml:"version,attr"`
+	ContentNamespace string   `xml:"xmlns:content,attr"`
+	AtomNamespace    string   `xml:"xmlns:atom,attr,omitempty"`
+	DarlingNamespace string   `xml:"xmlns:darling,attr,omitempty"`
+	Channel          *rssFeed
+}
+
+type rssFeed struct {
+	XMLName        xml.Name `xml:"channel"`
+	Title          string   `xml:"title"`       // required
+	Link           string   `xml:"link"`        // required
+	Description    string   `xml:"description"` // required
+	Language       string   `xml:"language,omitempty"`
+	Copyright      string   `xml:"copyright,omitempty"`
+	ManagingEditor string   `xml:"managingEditor,omitempty"` // Author used
+	PubDate        string   `xml:"pubDate,omitempty"`        // created or updated
+	LastBuildDate  string   `xml:"lastBuildDate,omitempty"`  // updated used
+	Image          *feeds.RssImage
+	AtomLink       *rssAtomLink
+	Items          []*rssItem `xml:"item"`
+}
+
+// rssAtomLink is the <atom:link rel="self"> recommended for RSS feeds
+type rssAtomLink struct {
+	XMLName xml.Name `xml:"atom:link"`
+	Href    string   `xml:"href,attr"`
+	Rel     string   `xml:"rel,attr"`
+	Type    string   `xml:"type,attr"`
+}
+
+type rssItem struct {
+	XMLName     xml.Name `xml:"item"`
+	Title       string   `xml:"title"`       // required
+	Link        string   `xml:"link"`        // required
+	Description string   `xml:"description"` // required
+	Content     *feeds.RssContent
+	Author      string `xml:"author,omitempty"`
+	Enclosure   *feeds.RssEnclosure
+	Categories  []string `xml:"category"`
+	Guid        *rssGuid `xml:"guid,omitempty"`    // Id used
+	PubDate     string   `xml:"pubDate,omitempty"` // created or updated
+	Source      *rssSource
+	ReadingTime int `xml:"darling:readingTime,omitempty"` // in minutes
+}
+
+// rssGuid is an item's id. Unless it's the item's own link, it's marked
+// as not being a link at all, since readers may otherwise follow it.
+type rssGuid struct {
+	Id          string `xml:",chardata"`
+	IsPermaLink string `xml:"isPermaLink,attr,omitempty"`
+}
+
+type rssSource struct {
+	XMLName xml.Name `xml:"source"`
+	Url     string   `xml:"url,attr"`
+	Title   string   `xml:",chardata"`
+}
+
+func newRssItem(i *Item) *rssItem {
+	item := &rssItem{
+		Title:       i.Title,
+		Link:        i.Link.Href,
+		Description: i.Description,
+		PubDate:     anyTimeFormat(time.RFC1123Z, i.Created, i.Updated),
+		Categories:  i.Categories,
+		ReadingTime: readingMinutes(i.ReadingTime),
+	}
+	if i.Id != "" {
+		item.Guid = &rssGuid{Id: i.Id}
+		permalink := strings.HasPrefix(i.Id, "http://") || strings.HasPrefix(i.Id, "https://")
+		if !permalink || i.Id != i.Link.Href {
+			item.Guid.IsPermaLink = "false"
+		}
+	}
+	if len(i.Content) > 0 {
+		item.Content = &feeds.RssContent{Content: i.Content}
+	}
+	if i.Enclosure != nil && i.Enclosure.Type != "" && i.Enclosure.Length != "" {
+		item.Enclosure = &feeds.RssEnclosure{Url: i.Enclosure.Url, Type: i.Enclosure.Type, Length: i.Enclosure.Length}
+	}
+	if i.Author != nil {
+		item.Author = i.Author.Name
+	}
+	if i.Source != nil {
+		// url is required; the feed's website is the next best thing
+		url := i.Source.URL
+		if url == "" {
+			url = i.Source.Link
+		}
+		if url != "" || i.Source.Title != "" {
+			item.Source = &rssSource{Url: url, Title: i.Source.Title}
+		}
+	}
+	return item
+}
+
+func FeedToRss(outfeed *Feed) (string, error) {
+	author := ""
+	if outfeed.Author != nil {
+		author = outfeed.Author.Email
+		if len(outfeed.Author.Name) > 0 {
+			author = fmt.Sprintf("%s (%s)", outfeed.Author.Email, outfeed.Author.Name)
+		}
+	}
+	channel := &rssFeed{
+		Title:          outfeed.Title,
+		Link:           outfeed.Link.Href,
+		Description:    outfeed.Description,
+		Language:       outfeed.Language,
+		Copyright:      outfeed.Copyright,
+		ManagingEditor: author,
+		PubDate:        anyTimeFormat(time.RFC1123Z, outfeed.Created, outfeed.Updated),
+		LastBuildDate:  anyTimeFormat(time.RFC1123Z, outfeed.Updated),
+	}
+	if outfeed.Image != nil {
+		image := outfeed.Image
+		channel.Image = &feeds.RssImage{Url: image.Url, Title: image.Title, Link: image.Link, Width: image.Width, Height: image.Height}
+	}
+	doc := &rssFeedXml{
+		Version:          "2.0",
+		ContentNamespace: "http://purl.org/rss/1.0/modules/content/",
+		Channel:          channel,
+	}
+	if outfeed.Self != "" {
+		doc.AtomNamespace = "http://www.w3.org/2005/Atom"
+		channel.AtomLink = &rssAtomLink{Href: outfeed.Self, Rel: "self", Type: "application/rss+xml"}
+	}
+	for _, i := range outfeed.Items {
+		item := newRssItem(i)
+		if item.ReadingTime > 0 {
+			doc.DarlingNamespace = Namespace
+		}
+		channel.Items = append(channel.Items, item)
+	}
+	return toXML(doc)
+}
@@ -0,0 +1,284 @@
+package output
+
+import (
+	"golang.org/x/net/html"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Policy is an allowlist of the HTML allowed in item descriptions and
+// content. Anything else is removed, keeping the text inside.
+type Policy struct {
+	// Elements maps each element allowed to the attributes allowed on
+	// it.
+	Elements map[string][]string `json:"elements"`
+	// Schemes are the URL schemes allowed in links and images; relative
+	// URLs are always allowed.
+	Schemes []string `json:"schemes"`
+}
+
+// DefaultPolicy allows text formatting, links, images and tables.
+var DefaultPolicy = Policy{
+	Elements: map[string][]string{
+		"a": {"href", "title"}, "abbr": {"title"}, "b": nil, "blockquote": {"cite"},
+		"br": nil, "caption": nil, "cite": nil, "code": nil, "dd": nil, "del": nil,
+		"div": nil, "dl": nil, "dt": nil, "em": nil, "figcaption": nil, "figure": nil,
+		"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil, "hr": nil,
+		"i": nil, "img": {"src", "alt", "title", "width", "height"}, "ins": nil,
+		"li": nil, "mark": nil, "ol": nil, "p": nil, "pre": nil, "q": {"cite"},
+		"s": nil, "small": nil, "span": nil, "strong": nil, "sub": nil, "sup": nil,
+		"table": nil, "tbody": nil, "td": {"colspan", "rowspan"}, "tfoot": nil,
+		"th": {"colspan", "rowspan"}, "thead": nil, "tr": nil, "u": nil, "ul": nil,
+	},
+	Schemes: []string{"http", "https", "mailto"},
+}
+
+// Elements removed along with everything inside them, whatever the
+// policy says
+var droppedElements = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"applet": true, "noscript": true, "template": true, "frame": true,
+	"frameset": true, "head": true, "title": true, "svg": true, "math": true,
+}
+
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "cite": true, "poster": true, "action": true,
+	"formaction": true, "background": true, "longdesc": true,
+}
+
+// Sanitize removes everything the policy doesn't allow from each item's
+// description and content. With plainText, all markup is removed, and
+// the text is escaped so that none is made from its entities.
+func Sanitize(items []*Item, policy Policy, plainText bool) {
+	clean := func(s string) string {
+		if plainText {
+			return html.EscapeString(PlainText(s))
+		}
+		return policy.Sanitize(s)
+	}
+	for _, item := range items {
+		item.Description = clean(item.Description)
+		item.Content = clean(item.Content)
+	}
+}
+
+// Sanitize cleans an HTML fragment. Scripts, styles and embedded frames
+// go entirely, as do tracking pixels, event handlers and links to
+// anything but the allowed schemes.
+func (policy Policy) Sanitize(fragment string) string {
+	if !strings.ContainsAny(fragment, "<&") {
+		return fragment
+	}
+	var b strings.Builder
+	open := []string{}
+	skip := 0
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		token := z.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if droppedElements[token.Data] {
+				if tt == html.StartTagToken && !isVoid(token.Data) {
+					skip++
+				}
+				continue
+			}
+			allowed, ok := policy.Elements[token.Data]
+			if skip > 0 || !ok || isTrackingPixel(token) {
+				continue
+			}
+			token.Attr = policy.attributes(token.Attr, allowed)
+			if isVoid(token.Data) {
+				token.Type = html.SelfClosingTagToken
+			} else if tt == html.StartTagToken {
+				open = append(open, token.Data)
+			} else {
+				// Only void elements can close themselves in HTML
+				token.Type = html.StartTagToken
+				b.WriteString(token.String())
+				token.Type = html.EndTagToken
+				token.Attr = nil
+			}
+			b.WriteString(token.String())
+		case html.EndTagToken:
+			if droppedElements[token.Data] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			// Close whatever was left open inside it
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == token.Data {
+					for _, name := range reversed(open[i:]) {
+						b.WriteString("</" + name + ">")
+					}
+					open = open[:i]
+					break
+				}
+			}
+		case html.TextToken:
+			if skip == 0 {
+				b.WriteString(html.EscapeString(token.Data))
+			}
+		}
+	}
+	for _, name := range reversed(open) {
+		b.WriteString("</" + name + ">")
+	}
+	return b.String()
+}
+
+// attributes keeps the allowed attributes, as long as any URLs in them
+// are safe.
+func (policy Policy) attributes(attrs []html.Attribute, allowed []string) []html.Attribute {
+	kept := []html.Attribute{}
+	for _, attr := range attrs {
+		if attr.Namespace != "" || !contains(allowed, attr.Key) {
+			continue
+		}
+		if urlAttributes[attr.Key] && !policy.safeURL(attr.Val) {
+			continue
+		}
+		if attr.Key == "srcset" && !policy.safeSrcset(attr.Val) {
+			continue
+		}
+		kept = append(kept, attr)
+	}
+	return kept
+}
+
+func (policy Policy) safeURL(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "" || contains(policy.Schemes, strings.ToLower(u.Scheme))
+}
+
+// safeSrcset checks each URL in a srcset, a list of URLs each followed by
+// an optional size.
+func (policy Policy) safeSrcset(srcset string) bool {
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) > 0 && !policy.safeURL(fields[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isTrackingPixel reports whether the token is an image too small to
+// see, which is only there to report that it was loaded.
+func isTrackingPixel(token html.Token) bool {
+	if token.Data != "img" {
+		return false
+	}
+	tiny := 0
+	for _, attr := range token.Attr {
+		if attr.Key == "width" || attr.Key == "height" {
+			if n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(attr.Val), "px")); err == nil && n <= 1 {
+				tiny++
+			}
+		}
+	}
+	return tiny > 0
+}
+
+// PlainText reduces an HTML fragment to its text. Paragraphs and other
+// blocks are kept apart by blank lines, and line breaks kept.
+func PlainText(fragment string) string {
+	if !strings.ContainsAny(fragment, "<&") {
+		return strings.TrimSpace(fragment)
+	}
+	var b strings.Builder
+	skip := 0
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return tidyText(b.String())
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			if droppedElements[tag] {
+				if tt == html.StartTagToken && !isVoid(tag) {
+					skip++
+				} else if tt == html.EndTagToken && skip > 0 {
+					skip--
+				}
+			} else if tag == "br" {
+				b.WriteString("\n")
+			} else if blockElements[tag] {
+				b.WriteString("\n\n")
+			}
+		case html.TextToken:
+			if skip == 0 {
+				b.Write(z.Text())
+			}
+		}
+	}
+}
+
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"dd": true, "div": true, "dl": true, "dt": true, "figcaption": true,
+	"figure": true, "footer": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "header": true, "hr": true, "li": true,
+	"main": true, "nav": true, "ol": true, "p": true, "pre": true,
+	"section": true, "table": true, "td": true, "th": true, "tr": true, "ul": true,
+}
+
+// tidyText collapses the whitespace within each line, and the blank
+// lines between them.
+func tidyText(s string) string {
+	lines := []string{}
+	blank := false
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			blank = len(lines) > 0
+			continue
+		}
+		if blank {
+			lines = append(lines, "")
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isVoid(name string) bool {
+	switch name {
+	case "area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "source", "track", "wbr":
+		return true
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func reversed(names []string) []string {
+	r := make([]string, len(names))
+	for i, name := range names {
+		r[len(names)-1-i] = name
+	}
+	return r
+}
@@ -0,0 +1,93 @@
+package output_test
+
+import (
+	"github.com/gorilla/feeds"
+	"github.com/snark/darling/pkg/output"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{`<p onclick="steal()">Hello <b>there</b></p>`, `<p>Hello <b>there</b></p>`},
+		{`Before<script>alert(1)</script> after`, `Before after`},
+		{`<iframe src="https://ads.example.com/"><p>Fallback</p></iframe>Text`, `Text`},
+		{`<a href="javascript:alert(1)" title="Go">Click</a>`, `<a title="Go">Click</a>`},
+		{`<a href="/relative" target="_blank">Link</a>`, `<a href="/relative">Link</a>`},
+		{`<img src="https://t.example.com/p.gif" width="1" height="1">Seen`, `Seen`},
+		{`<img src="https://example.com/cat.jpg" alt="Cat">`, `<img src="https://example.com/cat.jpg" alt="Cat"/>`},
+		{`<font color="red">Red</font> &amp; blue`, `Red &amp; blue`},
+		{`<ul><li><em>Unclosed`, `<ul><li><em>Unclosed</em></li></ul>`},
+		{`No markup at all`, `No markup at all`},
+	}
+	for _, c := range cases {
+		if got := output.DefaultPolicy.Sanitize(c.in); got != c.want {
+			t.Errorf("Sanitize(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestSanitizePolicy(t *testing.T) {
+	policy := output.Policy{
+		Elements: map[string][]string{"a": {"href"}},
+		Schemes:  []string{"https"},
+	}
+	in := `<p><a href="http://example.com/">Plain</a> <a href="https://example.com/">Secure</a></p>`
+	want := `<a>Plain</a> <a href="https://example.com/">Secure</a>`
+	if got := policy.Sanitize(in); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSanitizePolicyURLs(t *testing.T) {
+	policy := output.Policy{
+		Elements: map[string][]string{
+			"img":    {"src", "srcset"},
+			"video":  {"poster"},
+			"form":   {"action"},
+			"button": {"formaction"},
+			"td":     {"background"},
+		},
+		Schemes: []string{"https"},
+	}
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{`<img srcset="https://example.com/a.jpg 1x, https://example.com/b.jpg 2x">`, `<img srcset="https://example.com/a.jpg 1x, https://example.com/b.jpg 2x"/>`},
+		{`<img src="a.jpg" srcset="a.jpg 1x,javascript:alert(1) 2x">`, `<img src="a.jpg"/>`},
+		{`<video poster="javascript:alert(1)"></video>`, `<video></video>`},
+		{`<form action="javascript:alert(1)"><button formaction="javascript:alert(1)">Go</button></form>`, `<form><button>Go</button></form>`},
+		{`<td background="javascript:alert(1)">Cell</td>`, `<td>Cell</td>`},
+		{`<video poster="https://example.com/p.jpg"></video>`, `<video poster="https://example.com/p.jpg"></video>`},
+	}
+	for _, c := range cases {
+		if got := policy.Sanitize(c.in); got != c.want {
+			t.Errorf("Sanitize(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	in := `<h1>Title</h1><p>First   paragraph,<br>second line.</p><style>p {}</style><p>Last &amp; least</p>`
+	want := "Title\n\nFirst paragraph,\nsecond line.\n\nLast & least"
+	if got := output.PlainText(in); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSanitizePlainText(t *testing.T) {
+	items := []*output.Item{{Item: &feeds.Item{
+		Description: `<p>Use &lt;script&gt;alert(1)&lt;/script&gt; &amp; see</p>`,
+		Content:     `<b>Bold</b> &quot;quoted&quot;`,
+	}}}
+	output.Sanitize(items, output.DefaultPolicy, true)
+	if want := "Use &lt;script&gt;alert(1)&lt;/script&gt; &amp; see"; items[0].Description != want {
+		t.Errorf("got description %q, want %q", items[0].Description, want)
+	}
+	if want := "Bold &#34;quoted&#34;"; items[0].Content != want {
+		t.Errorf("got content %q, want %q", items[0].Content, want)
+	}
+}
@@ -0,0 +1,91 @@
+package output
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Summary is how long a summary may be: Length characters, or Length
+// sentences if Sentences is set. A zero Length means no summary.
+type Summary struct {
+	Length    int
+	Sentences bool
+}
+
+// ParseSummary reads a summary length given as N characters, or as Ns
+// for N sentences.
+func ParseSummary(spec string) (Summary, error) {
+	summary := Summary{}
+	length := strings.TrimSpace(spec)
+	if strings.HasSuffix(length, "s") {
+		summary.Sentences = true
+		length = strings.TrimSuffix(length, "s")
+	}
+	n, err := strconv.Atoi(length)
+	if err != nil || n < 0 {
+		return summary, fmt.Errorf("Summary length %q should be a number of characters, or of sentences like 2s", spec)
+	}
+	summary.Length = n
+	return summary, nil
+}
+
+// Summarize replaces each item's description with a plain text summary of
+// its content, or of the description itself if it has no content. The
+// summary is escaped, since the description is still HTML. With
+// dropContent, the content is removed.
+func Summarize(items []*Item, summary Summary, dropContent bool) {
+	for _, item := range items {
+		if summary.Length > 0 {
+			text := item.Content
+			if strings.TrimSpace(PlainText(text)) == "" {
+				text = item.Description
+			}
+			item.Description = html.EscapeString(summary.Summarize(text))
+		}
+		if dropContent {
+			item.Content = ""
+		}
+	}
+}
+
+// Summarize reduces an HTML fragment to the start of its text, cut at a
+// word boundary. An ellipsis marks text cut off mid-sentence.
+func (summary Summary) Summarize(fragment string) string {
+	words := strings.Fields(PlainText(fragment))
+	if summary.Sentences {
+		for i, word := range words {
+			if endsSentence(word) {
+				summary.Length--
+				if summary.Length == 0 && i < len(words)-1 {
+					return strings.Join(words[:i+1], " ")
+				}
+			}
+		}
+		return strings.Join(words, " ")
+	}
+	text := strings.Join(words, " ")
+	runes := []rune(text)
+	if len(runes) <= summary.Length {
+		return text
+	}
+	// Leave room for the ellipsis
+	cut := summary.Length - 1
+	for i := cut; i > 0; i-- {
+		if runes[i] == ' ' {
+			cut = i
+			break
+		}
+	}
+	return strings.TrimRightFunc(string(runes[:cut]), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) + "…"
+}
+
+// endsSentence reports whether a word is the last in its sentence.
+func endsSentence(word string) bool {
+	word = strings.TrimRight(word, `"'”’)]`)
+	return strings.HasSuffix(word, ".") || strings.HasSuffix(word, "!") || strings.HasSuffix(word, "?")
+}
@@ -0,0 +1,56 @@
+package output_test
+
+import (
+	"github.com/gorilla/feeds"
+	"github.com/snark/darling/pkg/output"
+	"testing"
+)
+
+const post = `<p>Darling is a <b>filtering</b> aggregator. It reads feeds!</p><p>Then it writes one, without the items you don't want.</p>`
+
+func TestSummarize(t *testing.T) {
+	cases := []struct {
+		spec string
+		want string
+	}{
+		{"40", "Darling is a filtering aggregator. It…"},
+		{"12", "Darling is…"},
+		{"500", "Darling is a filtering aggregator. It reads feeds! Then it writes one, without the items you don't want."},
+		{"1s", "Darling is a filtering aggregator."},
+		{"2s", "Darling is a filtering aggregator. It reads feeds!"},
+		{"5s", "Darling is a filtering aggregator. It reads feeds! Then it writes one, without the items you don't want."},
+	}
+	for _, c := range cases {
+		summary, err := output.ParseSummary(c.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := summary.Summarize(post); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.spec, got, c.want)
+		}
+	}
+	for _, spec := range []string{"", "s", "ten", "-3"} {
+		if _, err := output.ParseSummary(spec); err == nil {
+			t.Errorf("ParseSummary(%q) should have failed", spec)
+		}
+	}
+
+	items := []*output.Item{
+		{Item: &feeds.Item{Description: "A teaser", Content: post}},
+		{Item: &feeds.Item{Description: "<p>Only a description, but a long one.</p>"}},
+	}
+	output.Summarize(items, output.Summary{Length: 1, Sentences: true}, true)
+	if items[0].Description != "Darling is a filtering aggregator." || items[0].Content != "" {
+		t.Errorf("got %q and %q", items[0].Description, items[0].Content)
+	}
+	if items[1].Description != "Only a description, but a long one." {
+		t.Errorf("got %q", items[1].Description)
+	}
+
+	// Entities stay entities, rather than becoming markup
+	items = []*output.Item{{Item: &feeds.Item{Content: `<p>Try &lt;script&gt;alert(1)&lt;/script&gt; &amp; more.</p>`}}}
+	output.Summarize(items, output.Summary{Length: 100}, false)
+	if want := "Try &lt;script&gt;alert(1)&lt;/script&gt; &amp; more."; items[0].Description != want {
+		t.Errorf("got %q, want %q", items[0].Description, want)
+	}
+}